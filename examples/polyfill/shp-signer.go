@@ -13,6 +13,8 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+
+	"github.com/ruslano69/shp/pkg/converter"
 )
 
 func main() {
@@ -115,12 +117,19 @@ func signHTMLFile(htmlFile, privFile, pubFile string) {
 	pubBlock, _ := pem.Decode(pubPEM)
 	pubKeyBase64 := base64.StdEncoding.EncodeToString(pubBlock.Bytes)
 
-	// Create canonical content (simplified - remove script tags for signing)
-	canonical := string(htmlContent)
-	canonical = removeScriptTags(canonical)
+	// Build canonical content to hash: attribute order, whitespace and entity
+	// encoding don't survive a round-trip through a browser or another tool, so
+	// signing the raw bytes would make the signature fail verification for
+	// reasons that have nothing to do with tampering. Canonicalize gives a
+	// deterministic form that only changes if the actual DOM changes.
+	canonical, err := converter.Canonicalize(htmlContent)
+	if err != nil {
+		fmt.Printf("Error canonicalizing HTML: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Hash content
-	hash := sha256.Sum256([]byte(canonical))
+	hash := sha256.Sum256(canonical)
 
 	// Sign
 	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
@@ -151,18 +160,3 @@ func signHTMLFile(htmlFile, privFile, pubFile string) {
 	fmt.Printf("   Signature: %s...\n", signatureBase64[:40])
 	fmt.Printf("   Public Key: %s...\n", pubKeyBase64[:40])
 }
-
-// Remove script tags for canonical representation
-func removeScriptTags(html string) string {
-	// Simple regex replacement (in production use proper HTML parser)
-	result := html
-	for strings.Contains(result, "<script") {
-		start := strings.Index(result, "<script")
-		end := strings.Index(result[start:], "</script>")
-		if end == -1 {
-			break
-		}
-		result = result[:start] + result[start+end+9:]
-	}
-	return result
-}
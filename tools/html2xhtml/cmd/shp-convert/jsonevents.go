@@ -0,0 +1,88 @@
+// cmd/shp-convert/jsonevents.go
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+// Event одна запись потока -json, по объекту на строку, по аналогии с
+// cmd/internal/test2json из стандартной библиотеки Go (тот же принцип, что
+// использует "go test -json") - так CI и редакторы могут стримить прогресс
+// конвертации, не разбирая эмодзи-вывод человека
+type Event struct {
+	Action   string  `json:"Action"`
+	File     string  `json:"File,omitempty"`
+	Rule     string  `json:"Rule,omitempty"`
+	Original string  `json:"Original,omitempty"`
+	Fixed    string  `json:"Fixed,omitempty"`
+	Line     int     `json:"Line,omitempty"`
+	Elapsed  float64 `json:"Elapsed,omitempty"`
+	Output   string  `json:"Output,omitempty"`
+	Total    int     `json:"Total,omitempty"`
+	Success  int     `json:"Success,omitempty"`
+	Failed   int     `json:"Failed,omitempty"`
+	Changes  int     `json:"Changes,omitempty"`
+}
+
+// jsonReporter пишет Event по одному на строку в stdout. Потокобезопасен, чтобы
+// несколько воркеров worker pool могли писать события одновременно без
+// перемежения строк (в отличие от printProgress, тут сериализация нужна на
+// уровне одного вызова Encode, а не целого файла)
+type jsonReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newJSONReporter() *jsonReporter {
+	return &jsonReporter{enc: json.NewEncoder(os.Stdout)}
+}
+
+func (r *jsonReporter) emit(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(e)
+}
+
+func (r *jsonReporter) start(file string) {
+	r.emit(Event{Action: "start", File: file})
+}
+
+func (r *jsonReporter) change(file string, c converter.Change) {
+	r.emit(Event{
+		Action:   "change",
+		File:     file,
+		Rule:     c.Type.String(),
+		Original: c.Original,
+		Fixed:    c.Fixed,
+		Line:     c.Line,
+	})
+}
+
+func (r *jsonReporter) errorEvent(file, msg string) {
+	r.emit(Event{Action: "error", File: file, Output: msg})
+}
+
+func (r *jsonReporter) result(pass bool, file string, elapsed time.Duration) {
+	action := "pass"
+	if !pass {
+		action = "fail"
+	}
+	r.emit(Event{Action: action, File: file, Elapsed: elapsed.Seconds()})
+}
+
+func (r *jsonReporter) summary(stats *Stats) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	r.emit(Event{
+		Action:  "summary",
+		Total:   stats.TotalFiles,
+		Success: stats.SuccessCount,
+		Failed:  stats.FailedCount,
+		Changes: stats.TotalChanges,
+	})
+}
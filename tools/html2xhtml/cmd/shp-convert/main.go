@@ -2,18 +2,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/ruslano69/shp/tools/html2xhtml/pkg/converter"
+	"github.com/ruslano69/shp/pkg/cache"
+	"github.com/ruslano69/shp/pkg/converter"
 )
 
+// Stats накапливает статистику по всем файлам. Поля обновляются из воркеров
+// worker pool параллельно, поэтому все записи идут через mu
 type Stats struct {
+	mu            sync.Mutex
 	TotalFiles    int
 	SuccessCount  int
 	FailedCount   int
@@ -24,6 +31,39 @@ type Stats struct {
 	StartTime     time.Time
 }
 
+func (s *Stats) addQueued(size int64) {
+	s.mu.Lock()
+	s.TotalFiles++
+	s.TotalSize += size
+	s.mu.Unlock()
+}
+
+func (s *Stats) addSuccess(changes, errs int, finalSize int64) {
+	s.mu.Lock()
+	s.SuccessCount++
+	s.TotalChanges += changes
+	s.TotalErrors += errs
+	s.ProcessedSize += finalSize
+	s.mu.Unlock()
+}
+
+func (s *Stats) addFailure(errs int) {
+	s.mu.Lock()
+	s.FailedCount++
+	s.TotalErrors += errs
+	s.mu.Unlock()
+}
+
+// printMu сериализует вывод в stdout, чтобы строки прогресса от разных воркеров
+// worker pool не перемежались друг с другом
+var printMu sync.Mutex
+
+func printProgress(format string, args ...interface{}) {
+	printMu.Lock()
+	defer printMu.Unlock()
+	fmt.Printf(format, args...)
+}
+
 func main() {
 	// Флаги
 	inputDir := flag.String("input", ".", "Input directory with HTML files")
@@ -33,30 +73,48 @@ func main() {
 	verbose := flag.Bool("verbose", false, "Verbose output")
 	validateOnly := flag.Bool("validate-only", false, "Only validate, don't convert")
 	recursive := flag.Bool("recursive", true, "Process subdirectories")
-	
+	streamThreshold := flag.Int64("stream-threshold", 1<<20, "Use streaming conversion (no full-file buffering) for files larger than this many bytes; 0 disables streaming")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of files to convert concurrently")
+	ignore := flag.String("ignore", "", "Comma-separated gitignore-style glob patterns to skip (matched against the path relative to -input)")
+	jsonFormat := flag.Bool("json", false, "Emit one JSON event object per line (test2json-style: start/change/error/pass/fail/summary) instead of the human-readable report")
+	ruleEnable := flag.String("rule-enable", "", "Comma-separated Rule IDs to allow; if set, all other rules (built-in and custom) are skipped")
+	ruleDisable := flag.String("rule-disable", "", "Comma-separated Rule IDs to skip, even if allowed by -rule-enable")
+	cacheDir := flag.String("cache-dir", "", "Directory for an on-disk cache keyed by content hash (see pkg/cache); skips reconverting files unchanged since the last run; files above -stream-threshold are streamed and not cached. Disabled if empty")
+	cacheMaxMB := flag.Int("cache-max-mb", cache.DefaultMaxSizeMB, "Maximum size in MiB of -cache-dir before oldest entries are evicted")
+
 	flag.Parse()
 
+	ignorePatterns := splitCSV(*ignore)
+
 	// Инициализация
 	conv := converter.New()
 	stats := &Stats{StartTime: time.Now()}
 
-	fmt.Printf("🔧 SHP HTML→XHTML Converter\n")
-	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	fmt.Printf("Input:  %s\n", *inputDir)
-	if !*validateOnly {
-		fmt.Printf("Output: %s\n", *outputDir)
-	}
-	fmt.Printf("Mode:   ")
-	if *strict {
-		fmt.Printf("strict ")
+	var reporter *jsonReporter
+	if *jsonFormat {
+		reporter = newJSONReporter()
 	}
-	if *fix {
-		fmt.Printf("auto-fix ")
-	}
-	if *validateOnly {
-		fmt.Printf("validate-only")
+
+	if reporter == nil {
+		fmt.Printf("🔧 SHP HTML→XHTML Converter\n")
+		fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+		fmt.Printf("Input:  %s\n", *inputDir)
+		if !*validateOnly {
+			fmt.Printf("Output: %s\n", *outputDir)
+		}
+		fmt.Printf("Mode:   ")
+		if *strict {
+			fmt.Printf("strict ")
+		}
+		if *fix {
+			fmt.Printf("auto-fix ")
+		}
+		if *validateOnly {
+			fmt.Printf("validate-only")
+		}
+		fmt.Printf("\n")
+		fmt.Printf("Jobs:   %d\n\n", *jobs)
 	}
-	fmt.Printf("\n\n")
 
 	// Создание output директории
 	if !*validateOnly {
@@ -66,11 +124,42 @@ func main() {
 		}
 	}
 
-	// Обработка файлов
+	opts := converter.Options{
+		StrictMode:   *strict,
+		AutoFix:      *fix,
+		Verbose:      *verbose,
+		ValidateOnly: *validateOnly,
+		EnableRules:  splitCSV(*ruleEnable),
+		DisableRules: splitCSV(*ruleDisable),
+	}
+
+	var fileCache *cache.FileCache
+	if *cacheDir != "" && !*validateOnly {
+		fc, err := cache.NewFileCache(*cacheDir, *cacheMaxMB)
+		if err != nil {
+			fmt.Printf("❌ Failed to open -cache-dir %s: %v\n", *cacheDir, err)
+			os.Exit(1)
+		}
+		fileCache = fc
+	}
+
+	// Сбор файлов для обработки. Сам filepath.Walk остается последовательным
+	// (он и так по сути односвязный обход директории), а конвертация уже
+	// каждого найденного файла раздается в bounded worker pool ниже
+	var paths []string
 	walkFunc := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+
+		relPath, _ := filepath.Rel(*inputDir, path)
+		if matchesIgnore(relPath, ignorePatterns) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		if info.IsDir() {
 			if !*recursive && path != *inputDir {
 				return filepath.SkipDir
@@ -83,52 +172,174 @@ func main() {
 			return nil
 		}
 
-		stats.TotalFiles++
-		stats.TotalSize += info.Size()
-
-		return processFile(path, *inputDir, *outputDir, conv, stats, converter.Options{
-			StrictMode:   *strict,
-			AutoFix:      *fix,
-			Verbose:      *verbose,
-			ValidateOnly: *validateOnly,
-		})
+		stats.addQueued(info.Size())
+		paths = append(paths, path)
+		return nil
 	}
 
-	err := filepath.Walk(*inputDir, walkFunc)
-	if err != nil {
+	if err := filepath.Walk(*inputDir, walkFunc); err != nil {
 		fmt.Printf("❌ Walk error: %v\n", err)
 		os.Exit(1)
 	}
 
+	runWorkerPool(paths, *jobs, func(path string) error {
+		if !*validateOnly && *streamThreshold > 0 {
+			if info, err := os.Stat(path); err == nil && info.Size() > *streamThreshold {
+				return processFileStreaming(path, *inputDir, *outputDir, conv, stats, opts, reporter, fileCache)
+			}
+		}
+		return processFile(path, *inputDir, *outputDir, conv, stats, opts, reporter, fileCache)
+	})
+
 	// Итоговый отчет
-	printReport(stats)
+	if reporter != nil {
+		reporter.summary(stats)
+	} else {
+		printReport(stats)
+	}
 
 	if stats.FailedCount > 0 && *strict {
 		os.Exit(1)
 	}
 }
 
-func processFile(path, inputDir, outputDir string, conv converter.Converter, stats *Stats, opts converter.Options) error {
+// runWorkerPool раздает paths по max(jobs, 1) воркерам и ждет завершения всех
+func runWorkerPool(paths []string, jobs int, process func(path string) error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	pathCh := make(chan string)
+	var wg sync.WaitGroup
+
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range pathCh {
+				_ = process(path)
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		pathCh <- path
+	}
+	close(pathCh)
+
+	wg.Wait()
+}
+
+// splitCSV разбирает значение comma-separated флага (-ignore, -rule-enable,
+// -rule-disable) в список строк, пропуская пустые элементы
+func splitCSV(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var items []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			items = append(items, p)
+		}
+	}
+	return items
+}
+
+// writeOutput создает родительскую директорию outPath при необходимости и
+// записывает в него output - общая часть обычного и кеш-пути processFile
+func writeOutput(outPath string, output []byte) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outPath, output, 0644)
+}
+
+// matchesIgnore сообщает, нужно ли пропустить relPath - gitignore-style: шаблон
+// матчится либо против всего относительного пути, либо против имени последнего
+// компонента (как "node_modules" в gitignore матчит каталог на любом уровне)
+func matchesIgnore(relPath string, patterns []string) bool {
+	if relPath == "." || relPath == "" {
+		return false
+	}
+	base := filepath.Base(relPath)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, relPath); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func processFile(path, inputDir, outputDir string, conv converter.Converter, stats *Stats, opts converter.Options, reporter *jsonReporter, fileCache *cache.FileCache) error {
+	relPath, _ := filepath.Rel(inputDir, path)
+	startTime := time.Now()
+	if reporter != nil {
+		reporter.start(relPath)
+	}
+
 	// Чтение
 	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		fmt.Printf("❌ Error reading %s: %v\n", path, err)
-		stats.FailedCount++
+		if reporter != nil {
+			reporter.errorEvent(relPath, err.Error())
+			reporter.result(false, relPath, time.Since(startTime))
+		} else {
+			printProgress("❌ Error reading %s: %v\n", path, err)
+		}
+		stats.addFailure(0)
 		return nil
 	}
 
-	relPath, _ := filepath.Rel(inputDir, path)
+	// Кеш по хешу содержимого: файл, не изменившийся с прошлого запуска, не
+	// гоняется через конвертер заново - типичный случай для регулярно
+	// пересобираемого статического сайта (см. pkg/cache)
+	var cacheKey string
+	if fileCache != nil {
+		cacheKey = cache.Key(content, opts)
+		if cachedOutput, cachedResult, ok := fileCache.Get(cacheKey); ok {
+			outPath := filepath.Join(outputDir, relPath)
+			if err := writeOutput(outPath, cachedOutput); err != nil {
+				if reporter != nil {
+					reporter.errorEvent(relPath, err.Error())
+					reporter.result(false, relPath, time.Since(startTime))
+				} else {
+					printProgress("❌ Error writing %s: %v\n", outPath, err)
+				}
+				stats.addFailure(0)
+				return nil
+			}
+			stats.addSuccess(len(cachedResult.Changes), 0, cachedResult.FinalSize)
+			if reporter != nil {
+				reporter.result(true, relPath, time.Since(startTime))
+			} else {
+				printProgress("⚡ %s (cached)\n", relPath)
+			}
+			return nil
+		}
+	}
 
 	// Валидация или конвертация
 	if opts.ValidateOnly {
 		err := conv.Validate(content)
 		if err != nil {
-			fmt.Printf("❌ %s: %v\n", relPath, err)
-			stats.FailedCount++
-			stats.TotalErrors++
+			if reporter != nil {
+				reporter.errorEvent(relPath, err.Error())
+				reporter.result(false, relPath, time.Since(startTime))
+			} else {
+				printProgress("❌ %s: %v\n", relPath, err)
+			}
+			stats.addFailure(1)
 		} else {
-			fmt.Printf("✅ %s\n", relPath)
-			stats.SuccessCount++
+			if reporter != nil {
+				reporter.result(true, relPath, time.Since(startTime))
+			} else {
+				printProgress("✅ %s\n", relPath)
+			}
+			stats.addSuccess(0, 0, 0)
 		}
 		return nil
 	}
@@ -136,70 +347,191 @@ func processFile(path, inputDir, outputDir string, conv converter.Converter, sta
 	// Конвертация
 	result, err := conv.Convert(content, opts)
 	if err != nil {
-		fmt.Printf("❌ %s: conversion failed: %v\n", relPath, err)
-		stats.FailedCount++
-		stats.TotalErrors++
+		if reporter != nil {
+			reporter.errorEvent(relPath, err.Error())
+			reporter.result(false, relPath, time.Since(startTime))
+		} else {
+			printProgress("❌ %s: conversion failed: %v\n", relPath, err)
+		}
+		stats.addFailure(1)
 		return nil
 	}
 
 	if !result.Success && opts.StrictMode {
-		fmt.Printf("❌ %s: validation failed\n", relPath)
-		for _, e := range result.Errors {
-			fmt.Printf("   • %v\n", e)
+		if reporter != nil {
+			for _, e := range result.Errors {
+				reporter.errorEvent(relPath, fmt.Sprintf("%v", e))
+			}
+			reporter.result(false, relPath, time.Since(startTime))
+		} else {
+			var b strings.Builder
+			fmt.Fprintf(&b, "❌ %s: validation failed\n", relPath)
+			for _, e := range result.Errors {
+				fmt.Fprintf(&b, "   • %v\n", e)
+			}
+			printProgress("%s", b.String())
 		}
-		stats.FailedCount++
-		stats.TotalErrors += len(result.Errors)
+		stats.addFailure(len(result.Errors))
 		return nil
 	}
 
 	// Запись
 	outPath := filepath.Join(outputDir, relPath)
-	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
-		fmt.Printf("❌ Failed to create directory for %s\n", outPath)
-		stats.FailedCount++
+	if err := writeOutput(outPath, result.Output); err != nil {
+		if reporter != nil {
+			reporter.errorEvent(relPath, err.Error())
+			reporter.result(false, relPath, time.Since(startTime))
+		} else {
+			printProgress("❌ Error writing %s: %v\n", outPath, err)
+		}
+		stats.addFailure(0)
 		return nil
 	}
 
-	if err := ioutil.WriteFile(outPath, result.Output, 0644); err != nil {
-		fmt.Printf("❌ Error writing %s: %v\n", outPath, err)
-		stats.FailedCount++
-		return nil
+	if fileCache != nil && result.Success {
+		fileCache.Put(cacheKey, result.Output, result)
 	}
 
 	// Статистика
-	stats.SuccessCount++
-	stats.TotalChanges += len(result.Changes)
-	stats.TotalErrors += len(result.Errors)
-	stats.ProcessedSize += result.FinalSize
+	stats.addSuccess(len(result.Changes), len(result.Errors), result.FinalSize)
+
+	if reporter != nil {
+		for _, change := range result.Changes {
+			reporter.change(relPath, change)
+		}
+		reporter.result(true, relPath, time.Since(startTime))
+		return nil
+	}
 
 	// Вывод
+	var b strings.Builder
 	if opts.Verbose {
-		fmt.Printf("✅ %s (%d changes", relPath, len(result.Changes))
+		fmt.Fprintf(&b, "✅ %s (%d changes", relPath, len(result.Changes))
 		if len(result.Errors) > 0 {
-			fmt.Printf(", %d warnings", len(result.Errors))
+			fmt.Fprintf(&b, ", %d warnings", len(result.Errors))
 		}
-		fmt.Printf(")\n")
+		fmt.Fprintf(&b, ")\n")
 		for _, change := range result.Changes {
-			fmt.Printf("   • %s: %s → %s\n", change.Message, change.Original, change.Fixed)
+			fmt.Fprintf(&b, "   • %s: %s → %s\n", change.Message, change.Original, change.Fixed)
 		}
 	} else {
 		icon := "✅"
 		if len(result.Errors) > 0 {
 			icon = "⚠️"
 		}
-		fmt.Printf("%s %s", icon, relPath)
+		fmt.Fprintf(&b, "%s %s", icon, relPath)
 		if len(result.Changes) > 0 {
-			fmt.Printf(" (%d fixes)", len(result.Changes))
+			fmt.Fprintf(&b, " (%d fixes)", len(result.Changes))
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+	printProgress("%s", b.String())
+
+	return nil
+}
+
+// processFileStreaming конвертирует большой файл через conv.ConvertStream, читая
+// и записывая его потоково вместо ioutil.ReadFile/WriteFile - не буферизует
+// содержимое целиком в памяти, что нужно для многомегабайтных страниц
+// (см. BenchmarkConvert_Large в pkg/converter). Changes считаются через
+// opts.OnChange вместо накопления result.Changes. fileCache принимается только
+// ради единой сигнатуры с processFile - потоковый путь существует именно чтобы не
+// буферизовать большие файлы целиком, а cache.Key требует все содержимое сразу,
+// так что контентный кеш здесь осознанно не используется
+func processFileStreaming(path, inputDir, outputDir string, conv converter.Converter, stats *Stats, opts converter.Options, reporter *jsonReporter, fileCache *cache.FileCache) error {
+	relPath, _ := filepath.Rel(inputDir, path)
+	startTime := time.Now()
+	if reporter != nil {
+		reporter.start(relPath)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		if reporter != nil {
+			reporter.errorEvent(relPath, err.Error())
+			reporter.result(false, relPath, time.Since(startTime))
+		} else {
+			printProgress("❌ Error reading %s: %v\n", path, err)
+		}
+		stats.addFailure(0)
+		return nil
+	}
+	defer in.Close()
+
+	outPath := filepath.Join(outputDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		if reporter != nil {
+			reporter.errorEvent(relPath, err.Error())
+			reporter.result(false, relPath, time.Since(startTime))
+		} else {
+			printProgress("❌ Failed to create directory for %s\n", outPath)
+		}
+		stats.addFailure(0)
+		return nil
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		if reporter != nil {
+			reporter.errorEvent(relPath, err.Error())
+			reporter.result(false, relPath, time.Since(startTime))
+		} else {
+			printProgress("❌ Error creating %s: %v\n", outPath, err)
+		}
+		stats.addFailure(0)
+		return nil
+	}
+	defer out.Close()
+
+	var changeCount int
+	var changeLog strings.Builder
+	opts.OnChange = func(c converter.Change) {
+		changeCount++
+		if reporter != nil {
+			reporter.change(relPath, c)
+		} else if opts.Verbose {
+			fmt.Fprintf(&changeLog, "   • %s: %s → %s\n", c.Message, c.Original, c.Fixed)
 		}
-		fmt.Printf("\n")
 	}
 
+	result, err := conv.ConvertStream(context.Background(), in, out, opts)
+	if err != nil {
+		if reporter != nil {
+			reporter.errorEvent(relPath, err.Error())
+			reporter.result(false, relPath, time.Since(startTime))
+		} else {
+			printProgress("❌ %s: conversion failed: %v\n", relPath, err)
+		}
+		stats.addFailure(1)
+		return nil
+	}
+
+	stats.addSuccess(changeCount, len(result.Errors), result.FinalSize)
+
+	if reporter != nil {
+		reporter.result(true, relPath, time.Since(startTime))
+		return nil
+	}
+
+	var b strings.Builder
+	icon := "✅"
+	if len(result.Errors) > 0 {
+		icon = "⚠️"
+	}
+	fmt.Fprintf(&b, "%s %s (streamed", icon, relPath)
+	if changeCount > 0 {
+		fmt.Fprintf(&b, ", %d fixes", changeCount)
+	}
+	fmt.Fprintf(&b, ")\n")
+	b.WriteString(changeLog.String())
+	printProgress("%s", b.String())
+
 	return nil
 }
 
 func printReport(stats *Stats) {
 	duration := time.Since(stats.StartTime)
-	
+
 	fmt.Printf("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 	fmt.Printf("📊 Conversion Report\n")
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
@@ -208,15 +540,15 @@ func printReport(stats *Stats) {
 	fmt.Printf("Success:      %d\n", stats.SuccessCount)
 	fmt.Printf("Failed:       %d\n", stats.FailedCount)
 	fmt.Printf("Changes made: %d\n", stats.TotalChanges)
-	
+
 	if stats.TotalErrors > 0 {
 		fmt.Printf("Errors:       %d\n", stats.TotalErrors)
 	}
-	
+
 	if stats.ProcessedSize > 0 {
 		fmt.Printf("Input size:   %.2f KB\n", float64(stats.TotalSize)/1024)
 		fmt.Printf("Output size:  %.2f KB\n", float64(stats.ProcessedSize)/1024)
-		
+
 		ratio := float64(stats.ProcessedSize) / float64(stats.TotalSize) * 100
 		fmt.Printf("Size ratio:   %.1f%%\n", ratio)
 	}
@@ -0,0 +1,157 @@
+// cmd/shp-serve/batch.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// batchPartResult результат конвертации одной части запроса /batch, попадает в
+// итоговую сводку (см. batchSummary)
+type batchPartResult struct {
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	Changes int    `json:"changes,omitempty"`
+	Errors  int    `json:"errors,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// batchSummary агрегированная статистика по всем частям /batch - отправляется
+// финальной частью "summary" мультипарт-ответа
+type batchSummary struct {
+	Total   int               `json:"total"`
+	Success int               `json:"success"`
+	Failed  int               `json:"failed"`
+	Parts   []batchPartResult `json:"parts"`
+}
+
+// handleBatch - POST /batch: multipart/form-data со множеством частей HTML,
+// отдает multipart-ответ с одной application/xhtml+xml частью на каждую успешно
+// сконвертированную входную часть плюс финальную JSON-часть "summary". Части
+// читаются из запроса и пишутся в ответ по мере поступления через
+// multipart.Reader/Writer, без буферизации всего батча целиком в памяти.
+//
+// -max-body-bytes ограничивает каждую часть по отдельности (через io.LimitReader
+// на part, см. ниже), а не запрос целиком - произвольное число частей разумного
+// размера не должно упираться в лимит, рассчитанный на одну часть. Превышение
+// лимита одной частью не обрывает весь батч - эта часть просто считается неудачной.
+// Поверх этого -batch-max-total-bytes и -batch-max-parts ограничивают сам батч:
+// без них запрос из неограниченного числа частей под лимитом (или одной части
+// близко к нему, но бесконечно повторенной) мог бы вычитываться и конвертироваться
+// сколько угодно долго - в отличие от превышения per-part лимита, превышение любого
+// из них обрывает весь батч (как если бы поток частей кончился раньше времени).
+//
+// Ответ начинается (WriteHeader 200 + граница мультипарта) до того, как известно,
+// все ли части обработаются успешно - это неизбежная плата за потоковую отдачу
+// большого батча без буферизации; ошибка середины батча (чтения следующей части
+// запроса или создания части ответа) просто обрывает поток частей раньше времени,
+// после чего финальная "summary" часть по уже прочитанным частям все равно
+// дописывается в конец ответа.
+func (config *Config) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		newProblem(http.StatusMethodNotAllowed, "method-not-allowed", "POST required").write(w)
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		newProblem(http.StatusUnsupportedMediaType, "unsupported-media-type", "Content-Type must be multipart/form-data").write(w)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		newProblem(http.StatusBadRequest, "invalid-body", err.Error()).write(w)
+		return
+	}
+
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", mw.FormDataContentType())
+	w.WriteHeader(http.StatusOK)
+	defer mw.Close()
+
+	var summary batchSummary
+	var totalRead int64
+
+	for {
+		if config.BatchMaxParts > 0 && summary.Total >= config.BatchMaxParts {
+			break
+		}
+
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			break
+		}
+
+		name := part.FormName()
+		if name == "" {
+			name = part.FileName()
+		}
+
+		content, readErr := io.ReadAll(io.LimitReader(part, config.MaxBodyBytes+1))
+		part.Close()
+		summary.Total++
+		if readErr != nil {
+			summary.Failed++
+			summary.Parts = append(summary.Parts, batchPartResult{Name: name, Detail: readErr.Error()})
+			continue
+		}
+		if int64(len(content)) > config.MaxBodyBytes {
+			summary.Failed++
+			summary.Parts = append(summary.Parts, batchPartResult{Name: name, Detail: fmt.Sprintf("part exceeds %d bytes", config.MaxBodyBytes)})
+			continue
+		}
+
+		totalRead += int64(len(content))
+		if config.BatchMaxTotalBytes > 0 && totalRead > config.BatchMaxTotalBytes {
+			summary.Failed++
+			summary.Parts = append(summary.Parts, batchPartResult{Name: name, Detail: fmt.Sprintf("batch exceeds total limit of %d bytes", config.BatchMaxTotalBytes)})
+			break
+		}
+
+		result, convErr := convertWithCache(r.Context(), config.Config, content)
+		if convErr != nil {
+			summary.Failed++
+			summary.Parts = append(summary.Parts, batchPartResult{Name: name, Detail: convErr.Error()})
+			continue
+		}
+		if !result.Success && config.Options.StrictMode {
+			summary.Failed++
+			summary.Parts = append(summary.Parts, batchPartResult{Name: name, Errors: len(result.Errors), Detail: "strict mode: conversion produced errors"})
+			continue
+		}
+
+		summary.Success++
+		summary.Parts = append(summary.Parts, batchPartResult{Name: name, Success: true, Changes: len(result.Changes), Errors: len(result.Errors)})
+
+		partWriter, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {"application/xhtml+xml; charset=utf-8"},
+			"Content-Disposition": {fmt.Sprintf("form-data; name=%q; filename=%q", name, name)},
+			"X-SHP-Changes":       {strconv.Itoa(len(result.Changes))},
+			"X-SHP-Errors":        {strconv.Itoa(len(result.Errors))},
+		})
+		if err != nil {
+			break
+		}
+		partWriter.Write(result.Output)
+	}
+
+	summaryWriter, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"application/json"},
+		"Content-Disposition": {`form-data; name="summary"`},
+	})
+	if err == nil {
+		json.NewEncoder(summaryWriter).Encode(summary)
+	}
+}
@@ -0,0 +1,122 @@
+// cmd/shp-serve/convert.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ruslano69/shp/middleware"
+	"github.com/ruslano69/shp/pkg/cache"
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+// readBody ограничивает тело запроса max байт через http.MaxBytesReader и читает
+// его целиком. При ошибке возвращает problemDetails, готовый к немедленной отправке -
+// превышение лимита отдается как 413, остальные ошибки чтения как 400.
+func readBody(w http.ResponseWriter, r *http.Request, max int64) ([]byte, *problemDetails) {
+	r.Body = http.MaxBytesReader(w, r.Body, max)
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		return body, nil
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, newProblem(http.StatusRequestEntityTooLarge, "payload-too-large", fmt.Sprintf("request body exceeds %d bytes", max))
+	}
+	return nil, newProblem(http.StatusBadRequest, "invalid-body", err.Error())
+}
+
+// convertWithCache конвертирует body, обслуживая запрос из mwConfig.ContentCache,
+// если он задан и уже видел тот же sha256(body+опции) - см. pkg/cache. Промах кеша
+// конвертирует как обычно и, при успехе, сохраняет результат для следующих запросов.
+func convertWithCache(ctx context.Context, mwConfig middleware.Config, body []byte) (*converter.Result, error) {
+	if mwConfig.ContentCache == nil {
+		return mwConfig.Converter.ConvertWithContext(ctx, body, mwConfig.Options)
+	}
+
+	key := cache.Key(body, mwConfig.Options)
+	if out, cached, ok := mwConfig.ContentCache.Get(key); ok {
+		cached.Output = out
+		return cached, nil
+	}
+
+	result, err := mwConfig.Converter.ConvertWithContext(ctx, body, mwConfig.Options)
+	if err == nil && result.Success {
+		mwConfig.ContentCache.Put(key, result.Output, result)
+	}
+	return result, err
+}
+
+// requireHTMLPost проверяет метод и Content-Type, общие для /convert и /validate
+func requireHTMLPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		newProblem(http.StatusMethodNotAllowed, "method-not-allowed", "POST required").write(w)
+		return false
+	}
+	if !strings.Contains(r.Header.Get("Content-Type"), "text/html") {
+		newProblem(http.StatusUnsupportedMediaType, "unsupported-media-type", "Content-Type must be text/html").write(w)
+		return false
+	}
+	return true
+}
+
+// handleConvert - POST /convert: тело text/html → application/xhtml+xml, с
+// X-SHP-Changes/X-SHP-Errors, отражающими result.Changes/result.Errors
+func (config *Config) handleConvert(w http.ResponseWriter, r *http.Request) {
+	if !requireHTMLPost(w, r) {
+		return
+	}
+
+	body, problem := readBody(w, r, config.MaxBodyBytes)
+	if problem != nil {
+		problem.write(w)
+		return
+	}
+
+	result, err := convertWithCache(r.Context(), config.Config, body)
+	if err != nil {
+		newProblem(http.StatusUnprocessableEntity, "conversion-failed", err.Error()).write(w)
+		return
+	}
+	if !result.Success && config.Options.StrictMode {
+		newProblem(http.StatusUnprocessableEntity, "conversion-failed", "strict mode: conversion produced errors").write(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xhtml+xml; charset=utf-8")
+	w.Header().Set("X-SHP-Changes", strconv.Itoa(len(result.Changes)))
+	w.Header().Set("X-SHP-Errors", strconv.Itoa(len(result.Errors)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(result.Output)
+}
+
+// handleValidate - POST /validate: 200 с {"valid":true} если документ валиден,
+// иначе 422 с application/problem+json, описывающим первую найденную проблему
+func (config *Config) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if !requireHTMLPost(w, r) {
+		return
+	}
+
+	body, problem := readBody(w, r, config.MaxBodyBytes)
+	if problem != nil {
+		problem.write(w)
+		return
+	}
+
+	if err := config.Converter.ValidateWithContext(r.Context(), body); err != nil {
+		newProblem(http.StatusUnprocessableEntity, "validation-failed", err.Error()).write(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]bool{"valid": true})
+}
@@ -0,0 +1,126 @@
+// cmd/shp-serve/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ruslano69/shp/middleware"
+	"github.com/ruslano69/shp/pkg/cache"
+	"github.com/ruslano69/shp/pkg/converter"
+	shpprom "github.com/ruslano69/shp/pkg/converter/prometheus"
+)
+
+// Config конфигурация shp-serve. Встраивает middleware.Config, чтобы переиспользовать
+// те же Converter/Options/Metrics/ContentCache, которыми уже пользуются адаптеры в
+// middleware - shp-serve отличается от них только тем, что сам владеет HTTP-циклом
+// запрос/ответ, а не перехватывает ответ чужого обработчика.
+type Config struct {
+	middleware.Config
+	Addr               string
+	MaxBodyBytes       int64
+	BatchMaxTotalBytes int64
+	BatchMaxParts      int
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	ShutdownTimeout    time.Duration
+}
+
+func main() {
+	addr := flag.String("addr", ":8081", "Listen address")
+	maxBodyBytes := flag.Int64("max-body-bytes", 10<<20, "Maximum accepted request body size in bytes (applies per-part for -batch)")
+	batchMaxTotalBytes := flag.Int64("batch-max-total-bytes", 100<<20, "Maximum combined size in bytes of all parts read from one /batch request, on top of the per-part -max-body-bytes limit")
+	batchMaxParts := flag.Int("batch-max-parts", 1000, "Maximum number of parts accepted from one /batch request")
+	readTimeout := flag.Duration("read-timeout", 10*time.Second, "HTTP server read timeout")
+	writeTimeout := flag.Duration("write-timeout", 30*time.Second, "HTTP server write timeout")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "Time to wait for in-flight requests to finish on SIGINT/SIGTERM before forcing shutdown")
+	strict := flag.Bool("strict", false, "Strict mode: /convert and /batch parts fail (422) on any error instead of returning best-effort output")
+	fix := flag.Bool("fix", true, "Auto-fix common errors")
+	cacheDir := flag.String("cache-dir", "", "Directory for an on-disk cache keyed by content hash (see pkg/cache); skips reconverting identical input across requests. Disabled if empty")
+	cacheMaxMB := flag.Int("cache-max-mb", cache.DefaultMaxSizeMB, "Maximum size in MiB of -cache-dir before oldest entries are evicted")
+	enableMetrics := flag.Bool("metrics", false, "Expose Prometheus metrics at /metrics")
+
+	flag.Parse()
+
+	config := &Config{
+		Config: middleware.Config{
+			Options: converter.Options{
+				StrictMode: *strict,
+				AutoFix:    *fix,
+			},
+		},
+		Addr:               *addr,
+		MaxBodyBytes:       *maxBodyBytes,
+		BatchMaxTotalBytes: *batchMaxTotalBytes,
+		BatchMaxParts:      *batchMaxParts,
+		ReadTimeout:        *readTimeout,
+		WriteTimeout:       *writeTimeout,
+		ShutdownTimeout:    *shutdownTimeout,
+	}
+
+	var registry *promclient.Registry
+	if *enableMetrics {
+		registry = promclient.NewRegistry()
+		config.Metrics = shpprom.NewPrometheusMetrics(registry)
+	}
+
+	if config.Metrics != nil {
+		config.Converter = converter.NewWithMetrics(config.Metrics)
+	} else {
+		config.Converter = converter.New()
+	}
+
+	if *cacheDir != "" {
+		fc, err := cache.NewFileCache(*cacheDir, *cacheMaxMB)
+		if err != nil {
+			log.Fatalf("shp-serve: failed to open -cache-dir %s: %v", *cacheDir, err)
+		}
+		config.ContentCache = fc
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", config.handleConvert)
+	mux.HandleFunc("/validate", config.handleValidate)
+	mux.HandleFunc("/batch", config.handleBatch)
+	if registry != nil {
+		mux.Handle("/metrics", shpprom.Handler(registry))
+	}
+
+	srv := &http.Server{
+		Addr:         config.Addr,
+		Handler:      mux,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("shp-serve listening on %s", config.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("shp-serve: %v", err)
+		}
+	case <-sig:
+		log.Printf("shp-serve: shutting down, waiting up to %s for in-flight requests", config.ShutdownTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("shp-serve: graceful shutdown failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// cmd/shp-serve/problem.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// problemDetails тело ошибки в формате application/problem+json (RFC 7807),
+// которое /convert, /validate и /batch отдают вместо голого текста - так
+// не-Go билд-системы, вызывающие shp-serve из пайплайна, могут разобрать причину
+// отказа программно (по Title/Status), а не парсингом человекочитаемого сообщения.
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// newProblem создает problemDetails с Type "about:blank", как допускает RFC 7807
+// для ошибок, не имеющих своего постоянного URI
+func newProblem(status int, title, detail string) *problemDetails {
+	return &problemDetails{
+		Type:   "about:blank",
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// write отправляет p как JSON-тело ответа с соответствующими Content-Type и статусом
+func (p *problemDetails) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
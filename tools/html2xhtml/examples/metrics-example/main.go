@@ -2,20 +2,28 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 
+	promclient "github.com/prometheus/client_golang/prometheus"
+
 	"github.com/ruslano69/shp/middleware"
 	"github.com/ruslano69/shp/pkg/converter"
+	shpprom "github.com/ruslano69/shp/pkg/converter/prometheus"
 )
 
 func main() {
-	// Создаем middleware с метриками
+	// Метрики SHP регистрируются на собственном реестре, а не
+	// promclient.DefaultRegisterer, чтобы этот пример не подмешивал в /metrics
+	// стандартные go_*/process_* коллекторы чужого приложения
+	reg := promclient.NewRegistry()
+	metrics := shpprom.NewPrometheusMetrics(reg)
+
+	// Создаем middleware с Prometheus-метриками
 	config := middleware.Config{
-		EnableCache:   true,
-		EnableMetrics: true,
+		EnableCache: true,
+		Metrics:     metrics,
 		Options: converter.Options{
 			AutoFix: true,
 		},
@@ -30,29 +38,16 @@ func main() {
 		w.Write([]byte(html))
 	})
 
-	// Endpoint для метрик
-	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		stats := config.GetMetrics()
-		
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"total_conversions":      stats.TotalConversions,
-			"successful_conversions": stats.SuccessfulConversions,
-			"failed_conversions":     stats.FailedConversions,
-			"average_duration_ms":    stats.AverageDuration.Milliseconds(),
-			"total_bytes_processed":  stats.TotalBytesProcessed,
-			"total_bytes_output":     stats.TotalBytesOutput,
-			"changes_applied":        stats.ChangesApplied,
-			"errors_by_type":         stats.ErrorsByType,
-		})
-	})
+	// Endpoint для метрик в формате Prometheus text exposition - scrape'ится
+	// прямо Prometheus-ом, без написания собственного адаптера
+	mux.Handle("/metrics", shpprom.Handler(reg))
 
 	// Применяем middleware
 	handler := middleware.XHTMLMiddleware(config)(mux)
 
 	fmt.Println("Server starting on :8080")
 	fmt.Println("Visit http://localhost:8080/ for conversion")
-	fmt.Println("Visit http://localhost:8080/metrics for stats")
-	
+	fmt.Println("Visit http://localhost:8080/metrics for Prometheus metrics")
+
 	log.Fatal(http.ListenAndServe(":8080", handler))
 }
@@ -0,0 +1,170 @@
+// middleware/cache.go
+package middleware
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+// Значения по умолчанию для LRUCache, если Config их не задает
+const (
+	DefaultCacheMaxEntries = 1000
+	DefaultCacheMaxBytes   = 64 * 1024 * 1024 // 64 MiB
+	DefaultCacheTTL        = 5 * time.Minute
+)
+
+// CacheEntry закешированный результат конвертации вместе с ETag (hash от
+// несжатого XHTML), чтобы middleware могла отвечать "304 Not Modified" на условные
+// запросы. Encoding не пуст, если Body хранится в сжатом виде (gzip/deflate/br).
+// Preloads сохраняется отдельно от Body, чтобы Early Hints/Server Push отправлялись
+// и при повторной отдаче из кеша, а не только при первой конвертации.
+type CacheEntry struct {
+	Body     []byte
+	ETag     string
+	Encoding string
+	Preloads []converter.Preload
+}
+
+// NewCacheEntry считает ETag как sha256(body) и оборачивает несжатый body в CacheEntry
+func NewCacheEntry(body []byte) CacheEntry {
+	sum := sha256.Sum256(body)
+	return CacheEntry{Body: body, ETag: `"` + hex.EncodeToString(sum[:]) + `"`}
+}
+
+// Cache интерфейс кеша ответов middleware. По умолчанию используется LRUCache,
+// но пользователи могут подставить свою реализацию (Redis, memcached, ...) через Config.Cache
+type Cache interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, entry CacheEntry)
+}
+
+type lruItem struct {
+	key       string
+	value     CacheEntry
+	expiresAt time.Time
+}
+
+// LRUCache реализация Cache по умолчанию: ограничение по числу записей и суммарному
+// размеру тел, TTL на запись и вытеснение наименее недавно использованных записей
+type LRUCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	curBytes   int64
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+// NewLRUCache создает кеш с ограничениями maxEntries/maxBytes и сроком жизни записи ttl.
+// Нулевые значения заменяются на разумные значения по умолчанию.
+func NewLRUCache(maxEntries int, maxBytes int64, ttl time.Duration) *LRUCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultCacheMaxBytes
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &LRUCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+
+	item := el.Value.(*lruItem)
+	if time.Now().After(item.expiresAt) {
+		c.removeElement(el)
+		return CacheEntry{}, false
+	}
+
+	c.ll.MoveToFront(el)
+	return item.value, true
+}
+
+func (c *LRUCache) Set(key string, entry CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+
+	item := &lruItem{key: key, value: entry, expiresAt: time.Now().Add(c.ttl)}
+	el := c.ll.PushFront(item)
+	c.items[key] = el
+	c.curBytes += int64(len(entry.Body))
+
+	for (c.ll.Len() > c.maxEntries || c.curBytes > c.maxBytes) && c.ll.Len() > 0 {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *LRUCache) removeElement(el *list.Element) {
+	item := el.Value.(*lruItem)
+	c.ll.Remove(el)
+	delete(c.items, item.key)
+	c.curBytes -= int64(len(item.value.Body))
+}
+
+// flightGroup дедуплицирует одновременные конвертации одного и того же ключа:
+// пока первый запрос конвертирует страницу, остальные ждут его результат вместо
+// того чтобы конвертировать её заново
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg    sync.WaitGroup
+	entry CacheEntry
+	err   error
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flightCall)}
+}
+
+// Do выполняет fn не более одного раза одновременно для заданного ключа; все
+// конкурентные вызовы с тем же ключом дожидаются и получают один и тот же результат
+func (g *flightGroup) Do(key string, fn func() (CacheEntry, error)) (CacheEntry, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.entry, call.err
+	}
+
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.entry, call.err = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return call.entry, call.err
+}
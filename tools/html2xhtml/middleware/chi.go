@@ -0,0 +1,45 @@
+// middleware/chi.go
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ChiMiddleware оборачивает XHTMLMiddleware в func(http.Handler) http.Handler -
+// сигнатуру, которую ожидает chi.Router.Use (chi экспортирует только срез
+// Middlewares []func(http.Handler) http.Handler, отдельного именованного типа
+// chi.Middleware не существует). Дополнительно к обычному SkipPaths (сравнение
+// с r.URL.Path внутри shouldProcess) путь пропускается, если совпадает
+// зарегистрированный в chi route pattern (chi.RouteContext(r.Context()).RoutePattern(),
+// например "/api/*") - так можно исключить целый подроутер, не перечисляя каждый
+// его конкретный путь.
+func ChiMiddleware(config Config) func(http.Handler) http.Handler {
+	base := XHTMLMiddleware(config)
+
+	return func(next http.Handler) http.Handler {
+		wrapped := base(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if pattern := rctx.RoutePattern(); pattern != "" && matchesSkipPattern(pattern, config.SkipPaths) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// matchesSkipPattern проверяет совпадает ли зарегистрированный route pattern с одним
+// из SkipPaths (точное совпадение или SkipPaths как префикс, например "/api")
+func matchesSkipPattern(pattern string, skipPaths []string) bool {
+	for _, skip := range skipPaths {
+		if pattern == skip || strings.HasPrefix(pattern, skip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// middleware/convert.go
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ruslano69/shp/pkg/cache"
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+// convertBody декодирует (если сжато) и конвертирует тело ответа в XHTML, затем при
+// необходимости перекодирует обратно тем же Content-Encoding. Это общая часть
+// convert*Response для net/http, Gin, Echo, Fiber и Chi адаптеров - они отличаются
+// только тем, как каждый фреймворк перехватывает исходный ответ и что делает, если
+// convertBody вернула errNotConvertible (тогда вызывающий код сам отдает оригинал).
+func convertBody(ctx context.Context, contentType, contentEncoding string, raw []byte, config Config) (CacheEntry, error) {
+	if !strings.Contains(contentType, "text/html") {
+		return CacheEntry{}, errNotConvertible
+	}
+
+	body, decoded := decodeBody(raw, contentEncoding, config)
+
+	var contentKey string
+	if config.ContentCache != nil {
+		contentKey = cache.Key(body, config.Options)
+		if output, result, ok := config.ContentCache.Get(contentKey); ok {
+			return buildCacheEntry(output, result.Preloads, contentEncoding, decoded, config)
+		}
+	}
+
+	result, err := config.Converter.ConvertWithContext(ctx, body, config.Options)
+	if err != nil || !result.Success {
+		return CacheEntry{}, errNotConvertible
+	}
+
+	if config.ContentCache != nil {
+		config.ContentCache.Put(contentKey, result.Output, result)
+	}
+
+	return buildCacheEntry(result.Output, result.Preloads, contentEncoding, decoded, config)
+}
+
+// buildCacheEntry упаковывает готовый XHTML (свежесконвертированный либо взятый
+// из ContentCache) в CacheEntry, при необходимости перекодируя его обратно тем же
+// Content-Encoding, что был у исходного ответа.
+func buildCacheEntry(output []byte, preloads []converter.Preload, contentEncoding string, decoded bool, config Config) (CacheEntry, error) {
+	entry := NewCacheEntry(output)
+	entry.Preloads = preloads
+	if decoded {
+		reencoded, err := encodeBody(output, contentEncoding, config)
+		if err != nil {
+			return CacheEntry{}, errNotConvertible
+		}
+		entry.Body = reencoded
+		entry.Encoding = contentEncoding
+	}
+
+	return entry, nil
+}
@@ -3,6 +3,8 @@ package middleware
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"net/http"
 	"strings"
 
@@ -12,21 +14,34 @@ import (
 
 // EchoMiddleware для Echo framework
 func EchoMiddleware(config Config) echo.MiddlewareFunc {
+	if config.Metrics != nil {
+		config.metrics = config.Metrics
+	}
 	if config.Converter == nil {
-		if config.EnableMetrics {
+		switch {
+		case config.metrics != nil:
+			config.Converter = converter.NewWithMetrics(config.metrics)
+		case config.EnableMetrics:
 			config.metrics = converter.NewMetrics()
 			config.Converter = converter.NewWithMetrics(config.metrics)
-		} else {
+		default:
 			config.Converter = converter.New()
 		}
 	}
 	if len(config.OnlyExtensions) == 0 {
 		config.OnlyExtensions = []string{".html", ".htm"}
 	}
-
-	var cache *Cache
+	if config.EnableStreaming {
+		config.streamConverter = converter.NewStreamConverter(config.metrics)
+	}
+	if config.ContentEncodings == nil {
+		config.ContentEncodings = DefaultContentEncodings()
+	}
 	if config.EnableCache {
-		cache = newCache()
+		if config.Cache == nil {
+			config.Cache = NewLRUCache(config.CacheMaxEntries, config.CacheMaxBytes, config.CacheTTL)
+		}
+		config.flight = newFlightGroup()
 	}
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -36,52 +51,109 @@ func EchoMiddleware(config Config) echo.MiddlewareFunc {
 				return next(c)
 			}
 
-			// Проверка кеша
-			if cache != nil {
-				if cached, ok := cache.Get(c.Request().URL.Path); ok {
-					return c.Blob(200, "application/xhtml+xml; charset=utf-8", cached)
+			// Проверка кеша, включая условный запрос по ETag
+			if config.Cache != nil {
+				if entry, ok := config.Cache.Get(c.Request().URL.Path); ok {
+					if c.Request().Header.Get("If-None-Match") == entry.ETag {
+						c.Response().Header().Set("ETag", entry.ETag)
+						return c.NoContent(http.StatusNotModified)
+					}
+					sendEchoPreloadHints(c, entry.Preloads, config)
+					return writeEchoXHTML(c, entry)
 				}
 			}
 
-			// Перехват response
-			resWriter := &echoResponseWriter{
-				ResponseWriter: c.Response().Writer,
-				body:           &bytes.Buffer{},
+			if config.EnableStreaming {
+				return serveEchoStreaming(c, next, config)
 			}
-			c.Response().Writer = resWriter
 
-			if err := next(c); err != nil {
-				return err
+			convert := func() (CacheEntry, error) {
+				writer := &echoResponseWriter{
+					ResponseWriter: c.Response().Writer,
+					body:           &bytes.Buffer{},
+				}
+				c.Response().Writer = writer
+
+				if err := next(c); err != nil {
+					c.Response().Writer = writer.ResponseWriter
+					return CacheEntry{}, err
+				}
+				c.Response().Writer = writer.ResponseWriter
+				return convertEchoResponse(c, writer, config)
 			}
 
-			// Проверка content-type
-			contentType := c.Response().Header().Get("Content-Type")
-			if !strings.Contains(contentType, "text/html") {
-				// Не HTML, отдаем как есть
-				_, err := resWriter.ResponseWriter.Write(resWriter.body.Bytes())
+			var entry CacheEntry
+			var err error
+			if config.flight != nil {
+				entry, err = config.flight.Do(c.Request().URL.Path, convert)
+			} else {
+				entry, err = convert()
+			}
+			if err != nil {
+				if err == errNotConvertible {
+					return nil
+				}
 				return err
 			}
 
-			// Конвертация с контекстом
-			result, err := config.Converter.ConvertWithContext(c.Request().Context(), resWriter.body.Bytes(), config.Options)
-			if err != nil || !result.Success {
-				// Ошибка, отдаем оригинал
-				_, err := resWriter.ResponseWriter.Write(resWriter.body.Bytes())
-				return err
+			if config.Cache != nil {
+				config.Cache.Set(c.Request().URL.Path, entry)
 			}
+			sendEchoPreloadHints(c, entry.Preloads, config)
+			return writeEchoXHTML(c, entry)
+		}
+	}
+}
+
+// convertEchoResponse декодирует и конвертирует перехваченный ответ Echo. При
+// ошибке или не-HTML ответе пишет оригинал напрямую и возвращает errNotConvertible.
+func convertEchoResponse(c echo.Context, writer *echoResponseWriter, config Config) (CacheEntry, error) {
+	contentType := c.Response().Header().Get("Content-Type")
+	contentEncoding := c.Response().Header().Get("Content-Encoding")
+
+	entry, err := convertBody(c.Request().Context(), contentType, contentEncoding, writer.body.Bytes(), config)
+	if err != nil {
+		writer.ResponseWriter.Write(writer.body.Bytes())
+		return CacheEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// sendEchoPreloadHints отправляет "103 Early Hints" и/или HTTP/2 Server Push для
+// найденных в документе ресурсов. echo.Response сам не реализует http.Pusher - пушим
+// через его нижележащий http.ResponseWriter, если он его поддерживает (как http.go).
+func sendEchoPreloadHints(c echo.Context, preloads []converter.Preload, config Config) {
+	if len(preloads) == 0 {
+		return
+	}
 
-			// Кеширование
-			if cache != nil {
-				cache.Set(c.Request().URL.Path, result.Output)
+	if config.EnablePush {
+		if pusher, ok := c.Response().Writer.(http.Pusher); ok {
+			for _, p := range preloads {
+				pusher.Push(p.URL, nil)
 			}
+		}
+	}
 
-			// Отправка XHTML
-			c.Response().Writer = resWriter.ResponseWriter
-			return c.Blob(c.Response().Status, "application/xhtml+xml; charset=utf-8", result.Output)
+	if config.EnableEarlyHints {
+		header := c.Response().Header()
+		for _, p := range preloads {
+			header.Add("Link", preloadLinkHeader(p))
 		}
+		c.Response().WriteHeader(http.StatusEarlyHints)
 	}
 }
 
+func writeEchoXHTML(c echo.Context, entry CacheEntry) error {
+	c.Response().Header().Set("X-Converted-By", "SHP-Middleware")
+	c.Response().Header().Set("ETag", entry.ETag)
+	if entry.Encoding != "" {
+		c.Response().Header().Set("Content-Encoding", entry.Encoding)
+	}
+	return c.Blob(http.StatusOK, "application/xhtml+xml; charset=utf-8", entry.Body)
+}
+
 type echoResponseWriter struct {
 	http.ResponseWriter
 	body *bytes.Buffer
@@ -90,3 +162,99 @@ type echoResponseWriter struct {
 func (w *echoResponseWriter) Write(b []byte) (int, error) {
 	return w.body.Write(b)
 }
+
+// echoStreamWriter буферизует только первые sniffLen байт для определения Content-Type,
+// остальное сразу прогоняет через StreamConverter
+type echoStreamWriter struct {
+	http.ResponseWriter
+	config   Config
+	sniff    bytes.Buffer
+	streamed bool
+	passed   bool
+	pw       *io.PipeWriter
+	wait     func() (*converter.Result, error)
+	cacheBuf *bytes.Buffer
+}
+
+func (w *echoStreamWriter) Write(b []byte) (int, error) {
+	if w.streamed {
+		return w.pw.Write(b)
+	}
+	if w.passed {
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.sniff.Write(b)
+	if w.sniff.Len() < sniffLen {
+		return len(b), nil
+	}
+	w.decide()
+	return len(b), nil
+}
+
+func (w *echoStreamWriter) decide() {
+	contentType := w.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.sniff.Bytes())
+	}
+
+	if !strings.Contains(contentType, "text/html") {
+		w.passed = true
+		w.ResponseWriter.Write(w.sniff.Bytes())
+		return
+	}
+
+	w.streamed = true
+	pr, pw := io.Pipe()
+	w.pw = pw
+
+	var dst io.Writer = w.ResponseWriter
+	if w.config.Cache != nil {
+		w.cacheBuf = &bytes.Buffer{}
+		dst = io.MultiWriter(w.ResponseWriter, w.cacheBuf)
+	}
+
+	resultCh := make(chan streamOutcome, 1)
+	go func() {
+		result, err := w.config.streamConverter.Convert(context.Background(), pr, dst, w.config.Options)
+		pr.CloseWithError(err)
+		resultCh <- streamOutcome{result, err}
+	}()
+	w.wait = func() (*converter.Result, error) {
+		o := <-resultCh
+		return o.result, o.err
+	}
+
+	w.Header().Set("Content-Type", "application/xhtml+xml; charset=utf-8")
+	w.Header().Set("X-Converted-By", "SHP-Middleware")
+	w.Header().Del("Content-Length")
+	w.pw.Write(w.sniff.Bytes())
+}
+
+func (w *echoStreamWriter) finish() (*converter.Result, error) {
+	if w.streamed {
+		w.pw.Close()
+		return w.wait()
+	}
+	if !w.passed && w.sniff.Len() > 0 {
+		w.decide()
+		if w.streamed {
+			return w.finish()
+		}
+	}
+	return nil, nil
+}
+
+func serveEchoStreaming(c echo.Context, next echo.HandlerFunc, config Config) error {
+	writer := &echoStreamWriter{ResponseWriter: c.Response().Writer, config: config}
+	c.Response().Writer = writer
+
+	err := next(c)
+
+	c.Response().Writer = writer.ResponseWriter
+	result, finErr := writer.finish()
+	if finErr == nil && result != nil && result.Success && config.Cache != nil && writer.cacheBuf != nil {
+		config.Cache.Set(c.Request().URL.Path, NewCacheEntry(writer.cacheBuf.Bytes()))
+	}
+	return err
+}
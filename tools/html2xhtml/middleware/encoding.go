@@ -0,0 +1,121 @@
+// middleware/encoding.go
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+)
+
+// ContentEncoding декодирует и заново кодирует тело ответа для одного
+// значения заголовка Content-Encoding (gzip, deflate, br, ...)
+type ContentEncoding interface {
+	Decode(r io.Reader) (io.Reader, error)
+	Encode(w io.Writer) (io.WriteCloser, error)
+}
+
+// DefaultContentEncodings возвращает реестр кодировок "из коробки":
+// gzip, deflate и br (brotli)
+func DefaultContentEncodings() map[string]ContentEncoding {
+	return map[string]ContentEncoding{
+		"gzip":    gzipEncoding{},
+		"deflate": deflateEncoding{},
+		"br":      brotliEncoding{},
+	}
+}
+
+type gzipEncoding struct{}
+
+func (gzipEncoding) Decode(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipEncoding) Encode(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+type deflateEncoding struct{}
+
+func (deflateEncoding) Decode(r io.Reader) (io.Reader, error) {
+	return flate.NewReader(r), nil
+}
+
+func (deflateEncoding) Encode(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.DefaultCompression)
+}
+
+type brotliEncoding struct{}
+
+func (brotliEncoding) Decode(r io.Reader) (io.Reader, error) {
+	return brotli.NewReader(r), nil
+}
+
+func (brotliEncoding) Encode(w io.Writer) (io.WriteCloser, error) {
+	return brotli.NewWriter(w), nil
+}
+
+// isPassthroughEncoding проверяет, должна ли кодировка enc быть пропущена
+// без попытки декодирования/перекодирования (Config.PassthroughEncodings)
+func isPassthroughEncoding(enc string, passthrough []string) bool {
+	for _, p := range passthrough {
+		if p == enc {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeBody декодирует тело ответа согласно Content-Encoding, если он
+// известен реестру config.ContentEncodings и не находится в PassthroughEncodings.
+// Возвращает (decoded, true) при успешном декодировании, либо (body, false) если
+// декодирование не требуется или кодировка неизвестна/passthrough.
+func decodeBody(body []byte, contentEncoding string, config Config) ([]byte, bool) {
+	if contentEncoding == "" {
+		return body, false
+	}
+	if isPassthroughEncoding(contentEncoding, config.PassthroughEncodings) {
+		return body, false
+	}
+
+	enc, ok := config.ContentEncodings[contentEncoding]
+	if !ok {
+		return body, false
+	}
+
+	r, err := enc.Decode(bytes.NewReader(body))
+	if err != nil {
+		return body, false
+	}
+	decoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		return body, false
+	}
+	return decoded, true
+}
+
+// encodeBody заново кодирует преобразованный XHTML тем же Content-Encoding,
+// которым было закодировано исходное тело
+func encodeBody(body []byte, contentEncoding string, config Config) ([]byte, error) {
+	enc, ok := config.ContentEncodings[contentEncoding]
+	if !ok {
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	wc, err := enc.Encode(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := wc.Write(body); err != nil {
+		wc.Close()
+		return nil, err
+	}
+	if err := wc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
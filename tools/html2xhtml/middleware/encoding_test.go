@@ -0,0 +1,123 @@
+// middleware/encoding_test.go
+package middleware
+
+import (
+	"testing"
+)
+
+func TestDecodeBody_GzipRoundTrip(t *testing.T) {
+	config := Config{ContentEncodings: DefaultContentEncodings()}
+
+	original := []byte("<html><body>hi</body></html>")
+	compressed, err := encodeBody(original, "gzip", config)
+	if err != nil {
+		t.Fatalf("encodeBody: %v", err)
+	}
+	if string(compressed) == string(original) {
+		t.Fatal("expected encodeBody to actually compress the body")
+	}
+
+	decoded, ok := decodeBody(compressed, "gzip", config)
+	if !ok {
+		t.Fatal("expected decodeBody to report decoded = true for gzip")
+	}
+	if string(decoded) != string(original) {
+		t.Fatalf("decodeBody round trip = %q, want %q", decoded, original)
+	}
+}
+
+func TestDecodeBody_BrotliRoundTrip(t *testing.T) {
+	config := Config{ContentEncodings: DefaultContentEncodings()}
+
+	original := []byte("<html><body>hi</body></html>")
+	compressed, err := encodeBody(original, "br", config)
+	if err != nil {
+		t.Fatalf("encodeBody: %v", err)
+	}
+
+	decoded, ok := decodeBody(compressed, "br", config)
+	if !ok {
+		t.Fatal("expected decodeBody to report decoded = true for br")
+	}
+	if string(decoded) != string(original) {
+		t.Fatalf("decodeBody round trip = %q, want %q", decoded, original)
+	}
+}
+
+func TestDecodeBody_DeflateRoundTrip(t *testing.T) {
+	config := Config{ContentEncodings: DefaultContentEncodings()}
+
+	original := []byte("<html><body>hi</body></html>")
+	compressed, err := encodeBody(original, "deflate", config)
+	if err != nil {
+		t.Fatalf("encodeBody: %v", err)
+	}
+
+	decoded, ok := decodeBody(compressed, "deflate", config)
+	if !ok {
+		t.Fatal("expected decodeBody to report decoded = true for deflate")
+	}
+	if string(decoded) != string(original) {
+		t.Fatalf("decodeBody round trip = %q, want %q", decoded, original)
+	}
+}
+
+func TestDecodeBody_NoEncoding_ReturnsBodyUnchanged(t *testing.T) {
+	config := Config{ContentEncodings: DefaultContentEncodings()}
+
+	body := []byte("<html></html>")
+	decoded, ok := decodeBody(body, "", config)
+	if ok {
+		t.Fatal("expected decoded = false when Content-Encoding is empty")
+	}
+	if string(decoded) != string(body) {
+		t.Fatalf("decodeBody = %q, want unchanged %q", decoded, body)
+	}
+}
+
+func TestDecodeBody_PassthroughEncoding_ReturnsBodyUnchanged(t *testing.T) {
+	config := Config{
+		ContentEncodings:     DefaultContentEncodings(),
+		PassthroughEncodings: []string{"gzip"},
+	}
+
+	original := []byte("<html></html>")
+	compressed, err := encodeBody(original, "gzip", config)
+	if err != nil {
+		t.Fatalf("encodeBody: %v", err)
+	}
+
+	decoded, ok := decodeBody(compressed, "gzip", config)
+	if ok {
+		t.Fatal("expected decoded = false for a PassthroughEncodings entry")
+	}
+	if string(decoded) != string(compressed) {
+		t.Fatal("expected passthrough encoding to return the body untouched")
+	}
+}
+
+func TestDecodeBody_UnknownEncoding_ReturnsBodyUnchanged(t *testing.T) {
+	config := Config{ContentEncodings: DefaultContentEncodings()}
+
+	body := []byte("<html></html>")
+	decoded, ok := decodeBody(body, "x-unknown", config)
+	if ok {
+		t.Fatal("expected decoded = false for an unregistered Content-Encoding")
+	}
+	if string(decoded) != string(body) {
+		t.Fatal("expected unknown encoding to return the body untouched")
+	}
+}
+
+func TestEncodeBody_UnknownEncoding_ReturnsBodyUnchanged(t *testing.T) {
+	config := Config{ContentEncodings: DefaultContentEncodings()}
+
+	body := []byte("<html></html>")
+	encoded, err := encodeBody(body, "x-unknown", config)
+	if err != nil {
+		t.Fatalf("encodeBody: %v", err)
+	}
+	if string(encoded) != string(body) {
+		t.Fatal("expected unknown encoding to return the body untouched")
+	}
+}
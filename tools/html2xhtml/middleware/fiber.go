@@ -0,0 +1,121 @@
+// middleware/fiber.go
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+// FiberMiddleware для Fiber framework (fasthttp). В отличие от net/http/Gin/Echo,
+// fasthttp отдает тело ответа как []byte через c.Response().Body() и не поддерживает
+// io.Pipe-потоковую конвертацию так же просто, поэтому EnableStreaming здесь не
+// используется - ответ всегда буферизуется целиком, как в небуферизованных трех
+// адаптерах на шаге конвертации. Config.Options и кеш общие с остальными адаптерами.
+func FiberMiddleware(config Config) fiber.Handler {
+	if config.Metrics != nil {
+		config.metrics = config.Metrics
+	}
+	if config.Converter == nil {
+		switch {
+		case config.metrics != nil:
+			config.Converter = converter.NewWithMetrics(config.metrics)
+		case config.EnableMetrics:
+			config.metrics = converter.NewMetrics()
+			config.Converter = converter.NewWithMetrics(config.metrics)
+		default:
+			config.Converter = converter.New()
+		}
+	}
+	if len(config.OnlyExtensions) == 0 {
+		config.OnlyExtensions = []string{".html", ".htm"}
+	}
+	if config.ContentEncodings == nil {
+		config.ContentEncodings = DefaultContentEncodings()
+	}
+	if config.EnableCache {
+		if config.Cache == nil {
+			config.Cache = NewLRUCache(config.CacheMaxEntries, config.CacheMaxBytes, config.CacheTTL)
+		}
+		config.flight = newFlightGroup()
+	}
+
+	return func(c *fiber.Ctx) error {
+		path := c.Path()
+
+		// Проверка: нужно ли обрабатывать
+		if !shouldProcess(path, config) {
+			return c.Next()
+		}
+
+		// Проверка кеша, включая условный запрос по ETag
+		if config.Cache != nil {
+			if entry, ok := config.Cache.Get(path); ok {
+				if c.Get(fiber.HeaderIfNoneMatch) == entry.ETag {
+					c.Set(fiber.HeaderETag, entry.ETag)
+					return c.SendStatus(fiber.StatusNotModified)
+				}
+				sendFiberPreloadHints(c, entry.Preloads, config)
+				return writeFiberXHTML(c, entry)
+			}
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		convert := func() (CacheEntry, error) {
+			return convertFiberResponse(c, config)
+		}
+
+		var entry CacheEntry
+		var err error
+		if config.flight != nil {
+			entry, err = config.flight.Do(path, convert)
+		} else {
+			entry, err = convert()
+		}
+		if err != nil {
+			return nil // convertFiberResponse ничего не меняла - ответ уже в c.Response() как есть
+		}
+
+		if config.Cache != nil {
+			config.Cache.Set(path, entry)
+		}
+		sendFiberPreloadHints(c, entry.Preloads, config)
+		return writeFiberXHTML(c, entry)
+	}
+}
+
+// convertFiberResponse декодирует и конвертирует тело ответа, уже сформированное
+// нижележащим обработчиком в c.Response(). Если конвертация невозможна, тело и
+// заголовки ответа остаются как есть и вызывающий код просто отдает их клиенту.
+func convertFiberResponse(c *fiber.Ctx, config Config) (CacheEntry, error) {
+	contentType := string(c.Response().Header.Peek(fiber.HeaderContentType))
+	contentEncoding := string(c.Response().Header.Peek(fiber.HeaderContentEncoding))
+	raw := c.Response().Body()
+
+	return convertBody(c.UserContext(), contentType, contentEncoding, raw, config)
+}
+
+func writeFiberXHTML(c *fiber.Ctx, entry CacheEntry) error {
+	c.Set("X-Converted-By", "SHP-Middleware")
+	c.Set(fiber.HeaderETag, entry.ETag)
+	if entry.Encoding != "" {
+		c.Set(fiber.HeaderContentEncoding, entry.Encoding)
+	}
+	c.Set(fiber.HeaderContentType, "application/xhtml+xml; charset=utf-8")
+	return c.Status(fiber.StatusOK).Send(entry.Body)
+}
+
+// sendFiberPreloadHints при EnableEarlyHints добавляет Link: rel=preload заголовки
+// для найденных в документе ресурсов к основному ответу; отдельный промежуточный
+// ответ "103 Early Hints" не отправляется. fasthttp не предоставляет http.Pusher,
+// поэтому EnablePush для Fiber не поддерживается и тихо игнорируется.
+func sendFiberPreloadHints(c *fiber.Ctx, preloads []converter.Preload, config Config) {
+	if !config.EnableEarlyHints || len(preloads) == 0 {
+		return
+	}
+	for _, p := range preloads {
+		c.Response().Header.Add(fiber.HeaderLink, preloadLinkHeader(p))
+	}
+}
@@ -3,6 +3,9 @@ package middleware
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -11,21 +14,34 @@ import (
 
 // GinMiddleware для Gin framework
 func GinMiddleware(config Config) gin.HandlerFunc {
+	if config.Metrics != nil {
+		config.metrics = config.Metrics
+	}
 	if config.Converter == nil {
-		if config.EnableMetrics {
+		switch {
+		case config.metrics != nil:
+			config.Converter = converter.NewWithMetrics(config.metrics)
+		case config.EnableMetrics:
 			config.metrics = converter.NewMetrics()
 			config.Converter = converter.NewWithMetrics(config.metrics)
-		} else {
+		default:
 			config.Converter = converter.New()
 		}
 	}
 	if len(config.OnlyExtensions) == 0 {
 		config.OnlyExtensions = []string{".html", ".htm"}
 	}
-
-	var cache *Cache
+	if config.EnableStreaming {
+		config.streamConverter = converter.NewStreamConverter(config.metrics)
+	}
+	if config.ContentEncodings == nil {
+		config.ContentEncodings = DefaultContentEncodings()
+	}
 	if config.EnableCache {
-		cache = newCache()
+		if config.Cache == nil {
+			config.Cache = NewLRUCache(config.CacheMaxEntries, config.CacheMaxBytes, config.CacheTTL)
+		}
+		config.flight = newFlightGroup()
 	}
 
 	return func(c *gin.Context) {
@@ -35,48 +51,198 @@ func GinMiddleware(config Config) gin.HandlerFunc {
 			return
 		}
 
-		// Проверка кеша
-		if cache != nil {
-			if cached, ok := cache.Get(c.Request.URL.Path); ok {
-				c.Data(200, "application/xhtml+xml; charset=utf-8", cached)
+		// Проверка кеша, включая условный запрос по ETag
+		if config.Cache != nil {
+			if entry, ok := config.Cache.Get(c.Request.URL.Path); ok {
+				if c.GetHeader("If-None-Match") == entry.ETag {
+					c.Writer.Header().Set("ETag", entry.ETag)
+					c.Status(http.StatusNotModified)
+					c.Abort()
+					return
+				}
+				sendGinPreloadHints(c, entry.Preloads, config)
+				writeGinXHTML(c, entry)
 				c.Abort()
 				return
 			}
 		}
 
-		// Перехват response
-		writer := &ginResponseWriter{
-			ResponseWriter: c.Writer,
-			body:           &bytes.Buffer{},
+		if config.EnableStreaming {
+			serveGinStreaming(c, config)
+			return
 		}
-		c.Writer = writer
 
-		c.Next()
+		convert := func() (CacheEntry, error) {
+			writer := &ginResponseWriter{
+				ResponseWriter: c.Writer,
+				body:           &bytes.Buffer{},
+			}
+			c.Writer = writer
+			c.Next()
+			c.Writer = writer.ResponseWriter
+			return convertGinResponse(c, writer, config)
+		}
 
-		// Проверка content-type
-		contentType := c.Writer.Header().Get("Content-Type")
-		if !strings.Contains(contentType, "text/html") {
-			// Не HTML, отдаем как есть
-			writer.ResponseWriter.Write(writer.body.Bytes())
-			return
+		var entry CacheEntry
+		var err error
+		if config.flight != nil {
+			entry, err = config.flight.Do(c.Request.URL.Path, convert)
+		} else {
+			entry, err = convert()
+		}
+		if err != nil {
+			return // convertGinResponse уже отдала оригинал клиенту
 		}
 
-		// Конвертация с контекстом
-		result, err := config.Converter.ConvertWithContext(c.Request.Context(), writer.body.Bytes(), config.Options)
-		if err != nil || !result.Success {
-			// Ошибка, отдаем оригинал
-			writer.ResponseWriter.Write(writer.body.Bytes())
-			return
+		if config.Cache != nil {
+			config.Cache.Set(c.Request.URL.Path, entry)
+		}
+		sendGinPreloadHints(c, entry.Preloads, config)
+		writeGinXHTML(c, entry)
+	}
+}
+
+// convertGinResponse декодирует и конвертирует перехваченный ответ Gin. При ошибке
+// или не-HTML ответе пишет оригинал напрямую и возвращает errNotConvertible.
+func convertGinResponse(c *gin.Context, writer *ginResponseWriter, config Config) (CacheEntry, error) {
+	contentType := writer.ResponseWriter.Header().Get("Content-Type")
+	contentEncoding := writer.ResponseWriter.Header().Get("Content-Encoding")
+
+	entry, err := convertBody(c.Request.Context(), contentType, contentEncoding, writer.body.Bytes(), config)
+	if err != nil {
+		writer.ResponseWriter.Write(writer.body.Bytes())
+		return CacheEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// sendGinPreloadHints отправляет "103 Early Hints" и/или HTTP/2 Server Push для
+// найденных в документе ресурсов, используя Pusher() gin.ResponseWriter вместо
+// прямого приведения типа (gin не реализует http.Pusher напрямую)
+func sendGinPreloadHints(c *gin.Context, preloads []converter.Preload, config Config) {
+	if len(preloads) == 0 {
+		return
+	}
+
+	if config.EnablePush {
+		if pusher := c.Writer.Pusher(); pusher != nil {
+			for _, p := range preloads {
+				pusher.Push(p.URL, nil)
+			}
+		}
+	}
+
+	if config.EnableEarlyHints {
+		header := c.Writer.Header()
+		for _, p := range preloads {
+			header.Add("Link", preloadLinkHeader(p))
 		}
+		c.Status(http.StatusEarlyHints)
+	}
+}
+
+func writeGinXHTML(c *gin.Context, entry CacheEntry) {
+	c.Writer.Header().Set("X-Converted-By", "SHP-Middleware")
+	c.Writer.Header().Set("ETag", entry.ETag)
+	if entry.Encoding != "" {
+		c.Writer.Header().Set("Content-Encoding", entry.Encoding)
+	}
+	c.Data(http.StatusOK, "application/xhtml+xml; charset=utf-8", entry.Body)
+}
+
+// ginStreamWriter буферизует только первые sniffLen байт для определения Content-Type,
+// остальное сразу прогоняет через StreamConverter
+type ginStreamWriter struct {
+	gin.ResponseWriter
+	config   Config
+	sniff    bytes.Buffer
+	streamed bool
+	passed   bool
+	pw       *io.PipeWriter
+	wait     func() (*converter.Result, error)
+	cacheBuf *bytes.Buffer
+}
+
+func (w *ginStreamWriter) Write(b []byte) (int, error) {
+	if w.streamed {
+		return w.pw.Write(b)
+	}
+	if w.passed {
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.sniff.Write(b)
+	if w.sniff.Len() < sniffLen {
+		return len(b), nil
+	}
+	w.decide()
+	return len(b), nil
+}
+
+func (w *ginStreamWriter) decide() {
+	contentType := w.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.sniff.Bytes())
+	}
+
+	if !strings.Contains(contentType, "text/html") {
+		w.passed = true
+		w.ResponseWriter.Write(w.sniff.Bytes())
+		return
+	}
+
+	w.streamed = true
+	pr, pw := io.Pipe()
+	w.pw = pw
+
+	var dst io.Writer = w.ResponseWriter
+	if w.config.Cache != nil {
+		w.cacheBuf = &bytes.Buffer{}
+		dst = io.MultiWriter(w.ResponseWriter, w.cacheBuf)
+	}
+
+	resultCh := make(chan streamOutcome, 1)
+	go func() {
+		result, err := w.config.streamConverter.Convert(context.Background(), pr, dst, w.config.Options)
+		pr.CloseWithError(err)
+		resultCh <- streamOutcome{result, err}
+	}()
+	w.wait = func() (*converter.Result, error) {
+		o := <-resultCh
+		return o.result, o.err
+	}
 
-		// Кеширование
-		if cache != nil {
-			cache.Set(c.Request.URL.Path, result.Output)
+	w.Header().Set("Content-Type", "application/xhtml+xml; charset=utf-8")
+	w.Header().Set("X-Converted-By", "SHP-Middleware")
+	w.Header().Del("Content-Length")
+	w.pw.Write(w.sniff.Bytes())
+}
+
+func (w *ginStreamWriter) finish() (*converter.Result, error) {
+	if w.streamed {
+		w.pw.Close()
+		return w.wait()
+	}
+	if !w.passed && w.sniff.Len() > 0 {
+		w.decide()
+		if w.streamed {
+			return w.finish()
 		}
+	}
+	return nil, nil
+}
+
+func serveGinStreaming(c *gin.Context, config Config) {
+	writer := &ginStreamWriter{ResponseWriter: c.Writer, config: config}
+	c.Writer = writer
+
+	c.Next()
 
-		// Отправка XHTML
-		c.Writer = writer.ResponseWriter
-		c.Data(c.Writer.Status(), "application/xhtml+xml; charset=utf-8", result.Output)
+	c.Writer = writer.ResponseWriter
+	result, err := writer.finish()
+	if err == nil && result != nil && result.Success && config.Cache != nil && writer.cacheBuf != nil {
+		config.Cache.Set(c.Request.URL.Path, NewCacheEntry(writer.cacheBuf.Bytes()))
 	}
 }
 
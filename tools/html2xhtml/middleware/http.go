@@ -3,47 +3,57 @@ package middleware
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"net/http"
 	"strings"
-	"sync"
+	"time"
 
+	"github.com/ruslano69/shp/pkg/cache"
 	"github.com/ruslano69/shp/pkg/converter"
 )
 
+// sniffLen размер буфера, которого хватает чтобы определить Content-Type так же,
+// как это делает net/http.DetectContentType, прежде чем начать потоковую конвертацию
+const sniffLen = 512
+
 // Config конфигурация middleware
 type Config struct {
-	Converter      converter.Converter
-	Options        converter.Options
-	EnableCache    bool
-	EnableMetrics  bool
-	SkipPaths      []string // пути которые пропускаем
-	OnlyExtensions []string // только .html по умолчанию
-	metrics        converter.Metrics
-}
-
-// Cache простой кеш результатов
-type Cache struct {
-	mu    sync.RWMutex
-	items map[string][]byte
-}
-
-func newCache() *Cache {
-	return &Cache{
-		items: make(map[string][]byte),
-	}
-}
-
-func (c *Cache) Get(key string) ([]byte, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	val, ok := c.items[key]
-	return val, ok
-}
-
-func (c *Cache) Set(key string, val []byte) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.items[key] = val
+	Converter            converter.Converter
+	Options              converter.Options
+	EnableCache          bool
+	EnableMetrics        bool
+	// Metrics, если задан, используется вместо метрик, создаваемых автоматически при
+	// EnableMetrics (converter.NewMetrics(), только в памяти процесса) - передайте
+	// сюда converter/prometheus.NewPrometheusMetrics(reg), чтобы middleware писал
+	// метрики в Prometheus. GetMetrics() по-прежнему работает (PrometheusMetrics
+	// отражает ту же статистику в shadow-снэпшот, см. pkg/converter/prometheus);
+	// сам /metrics для скрейпинга собирается отдельно через Handler из pkg/converter/prometheus
+	// и реестр, на котором создан Metrics (middleware намеренно не зависит от
+	// client_golang - см. examples/metrics-example).
+	Metrics converter.Metrics
+	EnableStreaming      bool                       // конвертировать по мере поступления байт, без полной буферизации ответа
+	SkipPaths            []string                   // пути которые пропускаем
+	OnlyExtensions       []string                   // только .html по умолчанию
+	ContentEncodings     map[string]ContentEncoding // реестр кодировок для декодирования/перекодирования сжатых ответов
+	PassthroughEncodings []string                   // кодировки, которые не нужно декодировать/перекодировать (отдаются как есть)
+	Cache                Cache                      // кеш ответов; если nil и EnableCache - используется LRUCache
+	CacheMaxEntries      int                        // ограничение LRUCache по умолчанию по числу записей
+	CacheMaxBytes        int64                      // ограничение LRUCache по умолчанию по суммарному размеру тел
+	CacheTTL             time.Duration              // срок жизни записи в LRUCache по умолчанию
+	// ContentCache, если задан, используется вместо прямого вызова Converter для тел,
+	// чей sha256(содержимое+опции) уже встречался (см. pkg/cache.Key) - в отличие от
+	// Cache (который ключуется по пути запроса и живет в памяти процесса), ContentCache
+	// дедуплицирует одинаковый HTML под разными путями/query-строками и переживает
+	// перезапуск процесса, если это pkg/cache.FileCache. Полезен для пайплайна
+	// статического сайта за обратным прокси на этом middleware, где одна и та же
+	// неизменная страница отдается повторно при regenerate/rebuild.
+	ContentCache cache.Cache
+	EnableEarlyHints     bool                       // отправлять "103 Early Hints" с Link: rel=preload для найденных ресурсов
+	EnablePush           bool                       // звать http.Pusher.Push для найденных ресурсов при HTTP/2
+	metrics              converter.Metrics
+	streamConverter      *converter.StreamConverter
+	flight               *flightGroup
 }
 
 // responseWriter обертка для перехвата response
@@ -72,21 +82,34 @@ func (rw *responseWriter) WriteHeader(code int) {
 
 // XHTMLMiddleware для net/http
 func XHTMLMiddleware(config Config) func(http.Handler) http.Handler {
+	if config.Metrics != nil {
+		config.metrics = config.Metrics
+	}
 	if config.Converter == nil {
-		if config.EnableMetrics {
+		switch {
+		case config.metrics != nil:
+			config.Converter = converter.NewWithMetrics(config.metrics)
+		case config.EnableMetrics:
 			config.metrics = converter.NewMetrics()
 			config.Converter = converter.NewWithMetrics(config.metrics)
-		} else {
+		default:
 			config.Converter = converter.New()
 		}
 	}
 	if len(config.OnlyExtensions) == 0 {
 		config.OnlyExtensions = []string{".html", ".htm"}
 	}
-
-	var cache *Cache
+	if config.EnableStreaming {
+		config.streamConverter = converter.NewStreamConverter(config.metrics)
+	}
+	if config.ContentEncodings == nil {
+		config.ContentEncodings = DefaultContentEncodings()
+	}
 	if config.EnableCache {
-		cache = newCache()
+		if config.Cache == nil {
+			config.Cache = NewLRUCache(config.CacheMaxEntries, config.CacheMaxBytes, config.CacheTTL)
+		}
+		config.flight = newFlightGroup()
 	}
 
 	return func(next http.Handler) http.Handler {
@@ -97,49 +120,259 @@ func XHTMLMiddleware(config Config) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Проверка кеша
-			if cache != nil {
-				if cached, ok := cache.Get(r.URL.Path); ok {
-					writeXHTML(w, cached, http.StatusOK)
+			// Проверка кеша, включая условный запрос по ETag
+			if config.Cache != nil {
+				if entry, ok := config.Cache.Get(r.URL.Path); ok {
+					if r.Header.Get("If-None-Match") == entry.ETag {
+						w.Header().Set("ETag", entry.ETag)
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+					sendPreloadHints(w, entry.Preloads, config)
+					writeXHTMLWithETag(w, entry, http.StatusOK)
 					return
 				}
 			}
 
-			// Перехват response
-			rw := newResponseWriter(w)
-			next.ServeHTTP(rw, r)
-
-			// Проверка content-type
-			contentType := rw.Header().Get("Content-Type")
-			if !strings.Contains(contentType, "text/html") {
-				// Не HTML, отдаем как есть
-				copyHeaders(w, rw)
-				w.WriteHeader(rw.statusCode)
-				w.Write(rw.buf.Bytes())
+			if config.EnableStreaming {
+				serveStreaming(w, r, next, config)
 				return
 			}
 
-			// Конвертация с контекстом
-			result, err := config.Converter.ConvertWithContext(r.Context(), rw.buf.Bytes(), config.Options)
-			if err != nil || !result.Success {
-				// Ошибка конвертации, отдаем оригинал
-				copyHeaders(w, rw)
-				w.WriteHeader(rw.statusCode)
-				w.Write(rw.buf.Bytes())
-				return
+			// Singleflight: только один запрос конвертирует данный путь одновременно,
+			// остальные дожидаются его результата
+			ran := false
+			convert := func() (CacheEntry, error) {
+				ran = true
+				rw := newResponseWriter(w)
+				next.ServeHTTP(rw, r)
+				return convertBufferedResponse(r, w, rw, config)
 			}
 
-			// Кеширование
-			if cache != nil {
-				cache.Set(r.URL.Path, result.Output)
+			var entry CacheEntry
+			var err error
+			if config.flight != nil {
+				entry, err = config.flight.Do(r.URL.Path, convert)
+			} else {
+				entry, err = convert()
+			}
+			if err != nil {
+				// Если convert выполнялся в этом запросе, convertBufferedResponse уже
+				// отдала оригинал клиенту; запрос, дождавшийся чужой конвертации,
+				// отдает оригинал сам
+				if !ran {
+					next.ServeHTTP(w, r)
+				}
+				return
 			}
 
-			// Отправка XHTML
-			writeXHTML(w, result.Output, rw.statusCode)
+			if config.Cache != nil {
+				config.Cache.Set(r.URL.Path, entry)
+			}
+			sendPreloadHints(w, entry.Preloads, config)
+			writeXHTMLWithETag(w, entry, http.StatusOK)
 		})
 	}
 }
 
+// errNotConvertible маркер-ошибка: convertBufferedResponse уже записала ответ сама
+// (оригинал без изменений), вызывающему коду писать больше ничего не нужно
+var errNotConvertible = &converter.Error{Code: converter.ErrConversionFailed, Message: "response already written as-is"}
+
+// convertBufferedResponse декодирует и конвертирует уже буферизованный ответ и
+// возвращает готовую к кешированию запись. Если исходный ответ был сжат, итоговый
+// XHTML перекодируется тем же Content-Encoding и хранится в кеше уже сжатым, чтобы
+// повторная отдача не требовала перекодирования. Если конвертация невозможна (не
+// HTML, ошибка), она сама отдает клиенту оригинальный ответ и возвращает errNotConvertible.
+func convertBufferedResponse(r *http.Request, w http.ResponseWriter, rw *responseWriter, config Config) (CacheEntry, error) {
+	contentType := rw.Header().Get("Content-Type")
+	contentEncoding := rw.Header().Get("Content-Encoding")
+
+	entry, err := convertBody(r.Context(), contentType, contentEncoding, rw.buf.Bytes(), config)
+	if err != nil {
+		copyHeaders(w, rw)
+		w.WriteHeader(rw.statusCode)
+		w.Write(rw.buf.Bytes())
+		return CacheEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// writeXHTMLWithETag пишет закешированную (или только что сконвертированную) запись
+// клиенту с учетом её Content-Encoding и ETag
+func writeXHTMLWithETag(w http.ResponseWriter, entry CacheEntry, status int) {
+	if entry.Encoding != "" {
+		w.Header().Set("Content-Encoding", entry.Encoding)
+	}
+	w.Header().Set("ETag", entry.ETag)
+	writeXHTML(w, entry.Body, status)
+}
+
+// preloadLinkHeader формирует значение заголовка Link: rel=preload для одного
+// найденного ресурса, пригодное как для "103 Early Hints", так и для обычного ответа
+func preloadLinkHeader(p converter.Preload) string {
+	header := "<" + p.URL + ">; rel=preload; as=" + p.As
+	if p.Type != "" {
+		header += `; type="` + p.Type + `"`
+	}
+	return header
+}
+
+// sendPreloadHints отправляет клиенту найденные в документе ресурсы заранее:
+// "103 Early Hints" с Link-заголовками (EnableEarlyHints) и/или HTTP/2 Server Push
+// (EnablePush, если w поддерживает http.Pusher). Вызывается до записи основного
+// ответа, чтобы клиент мог начать загрузку ресурсов параллельно с ожиданием тела.
+func sendPreloadHints(w http.ResponseWriter, preloads []converter.Preload, config Config) {
+	if len(preloads) == 0 {
+		return
+	}
+
+	if config.EnablePush {
+		if pusher, ok := w.(http.Pusher); ok {
+			for _, p := range preloads {
+				pusher.Push(p.URL, nil)
+			}
+		}
+	}
+
+	if config.EnableEarlyHints {
+		header := w.Header()
+		for _, p := range preloads {
+			header.Add("Link", preloadLinkHeader(p))
+		}
+		w.WriteHeader(http.StatusEarlyHints)
+	}
+}
+
+// streamingResponseWriter перехватывает только первые sniffLen байт ответа (чтобы
+// определить Content-Type так же, как стандартный net/http), а всё остальное сразу
+// пропускает через StreamConverter в реальный http.ResponseWriter.
+type streamingResponseWriter struct {
+	http.ResponseWriter
+	config     Config
+	statusCode int
+	headerSent bool
+
+	sniff    bytes.Buffer
+	streamed bool // true если решили, что это HTML и мы уже начали потоковую конвертацию
+	passed   bool // true если решили, что это не HTML и пишем напрямую
+
+	pw   *io.PipeWriter
+	wait func() (*converter.Result, error)
+
+	cacheBuf *bytes.Buffer
+	path     string
+}
+
+func (rw *streamingResponseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+}
+
+func (rw *streamingResponseWriter) Write(b []byte) (int, error) {
+	if rw.streamed {
+		return rw.pw.Write(b)
+	}
+	if rw.passed {
+		return rw.ResponseWriter.Write(b)
+	}
+
+	rw.sniff.Write(b)
+	if rw.sniff.Len() < sniffLen {
+		return len(b), nil
+	}
+	rw.decide()
+	return len(b), nil
+}
+
+// decide определяет Content-Type по заголовку или по сниффу и либо запускает
+// потоковую конвертацию, либо переключается на прямую передачу байт как есть.
+func (rw *streamingResponseWriter) decide() {
+	contentType := rw.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(rw.sniff.Bytes())
+	}
+
+	if !strings.Contains(contentType, "text/html") {
+		rw.passed = true
+		rw.flushPassthrough()
+		return
+	}
+
+	rw.streamed = true
+	pr, pw := io.Pipe()
+	rw.pw = pw
+
+	var dst io.Writer = rw.ResponseWriter
+	if rw.config.Cache != nil {
+		rw.cacheBuf = &bytes.Buffer{}
+		dst = io.MultiWriter(rw.ResponseWriter, rw.cacheBuf)
+	}
+
+	resultCh := make(chan streamOutcome, 1)
+	go func() {
+		result, err := rw.config.streamConverter.Convert(context.Background(), pr, dst, rw.config.Options)
+		pr.CloseWithError(err)
+		resultCh <- streamOutcome{result, err}
+	}()
+	rw.wait = func() (*converter.Result, error) {
+		o := <-resultCh
+		return o.result, o.err
+	}
+
+	rw.ResponseWriter.Header().Set("Content-Type", "application/xhtml+xml; charset=utf-8")
+	rw.ResponseWriter.Header().Set("X-Converted-By", "SHP-Middleware")
+	rw.ResponseWriter.Header().Del("Content-Length")
+	rw.ResponseWriter.WriteHeader(rw.statusCode)
+
+	rw.pw.Write(rw.sniff.Bytes())
+}
+
+func (rw *streamingResponseWriter) flushPassthrough() {
+	copyHeaders(rw.ResponseWriter, rw)
+	rw.ResponseWriter.WriteHeader(rw.statusCode)
+	rw.ResponseWriter.Write(rw.sniff.Bytes())
+}
+
+// finish завершает запись и возвращает итоговый результат конвертации, если она
+// происходила (nil, если ответ не был HTML или был пустым; ответ короче sniffLen
+// определяется и конвертируется здесь же)
+func (rw *streamingResponseWriter) finish() (*converter.Result, error) {
+	if rw.streamed {
+		rw.pw.Close()
+		result, err := rw.wait()
+		if err == nil && rw.config.Cache != nil && result.Success {
+			rw.config.Cache.Set(rw.path, NewCacheEntry(rw.cacheBuf.Bytes()))
+		}
+		return result, err
+	}
+	if !rw.passed && rw.sniff.Len() > 0 {
+		// ответ меньше sniffLen и так и не был решен — добираем здесь
+		rw.decide()
+		if rw.streamed {
+			return rw.finish()
+		}
+	}
+	return nil, nil
+}
+
+type streamOutcome struct {
+	result *converter.Result
+	err    error
+}
+
+// serveStreaming обслуживает один запрос в потоковом режиме
+func serveStreaming(w http.ResponseWriter, r *http.Request, next http.Handler, config Config) {
+	rw := &streamingResponseWriter{
+		ResponseWriter: w,
+		config:         config,
+		statusCode:     http.StatusOK,
+		path:           r.URL.Path,
+	}
+	next.ServeHTTP(rw, r)
+	rw.finish()
+}
+
 func shouldProcess(path string, config Config) bool {
 	// Пропуск путей
 	for _, skip := range config.SkipPaths {
@@ -0,0 +1,148 @@
+// middleware/http_test.go
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+func htmlHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}
+}
+
+func TestXHTMLMiddleware_ConvertsHTMLResponse(t *testing.T) {
+	handler := XHTMLMiddleware(Config{
+		Options: converter.Options{AutoFix: true},
+	})(htmlHandler(`<DIV>hi</DIV>`))
+
+	req := httptest.NewRequest(http.MethodGet, "/page.html", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, "application/xhtml+xml") {
+		t.Errorf("Content-Type = %q, want application/xhtml+xml", ct)
+	}
+	if got := rec.Body.String(); !strings.Contains(got, "<div>hi</div>") {
+		t.Errorf("body = %q, want lowercased <div>hi</div>", got)
+	}
+}
+
+func TestXHTMLMiddleware_SkipsNonHTMLExtensions(t *testing.T) {
+	var called bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.Write([]byte("plain"))
+	})
+	handler := XHTMLMiddleware(Config{Options: converter.Options{AutoFix: true}})(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/script.js", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected next handler to run for a path outside OnlyExtensions")
+	}
+	if rec.Body.String() != "plain" {
+		t.Errorf("body = %q, want untouched passthrough", rec.Body.String())
+	}
+}
+
+func TestXHTMLMiddleware_CacheHitSkipsHandlerOnSecondRequest(t *testing.T) {
+	var calls int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<p>hi</p>`))
+	})
+	handler := XHTMLMiddleware(Config{
+		Options:     converter.Options{AutoFix: true},
+		EnableCache: true,
+	})(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/page.html", nil)
+
+	rec1 := httptest.NewRecorder()
+	handler.ServeHTTP(rec1, req)
+	rec2 := httptest.NewRecorder()
+	handler.ServeHTTP(rec2, req)
+
+	if calls != 1 {
+		t.Fatalf("next handler called %d times, want 1 (second request should be served from cache)", calls)
+	}
+	if rec1.Body.String() != rec2.Body.String() {
+		t.Errorf("cached response body = %q, want same as first response %q", rec2.Body.String(), rec1.Body.String())
+	}
+}
+
+func TestXHTMLMiddleware_CacheHit_ConditionalRequestGets304(t *testing.T) {
+	next := htmlHandler(`<p>hi</p>`)
+	handler := XHTMLMiddleware(Config{
+		Options:     converter.Options{AutoFix: true},
+		EnableCache: true,
+	})(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/page.html", nil)
+	rec1 := httptest.NewRecorder()
+	handler.ServeHTTP(rec1, req)
+	etag := rec1.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag to be set on first response")
+	}
+
+	req2 := httptest.NewRequest(http.MethodGet, "/page.html", nil)
+	req2.Header.Set("If-None-Match", etag)
+	rec2 := httptest.NewRecorder()
+	handler.ServeHTTP(rec2, req2)
+
+	if rec2.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304 for matching If-None-Match", rec2.Code)
+	}
+}
+
+func TestXHTMLMiddleware_Streaming_ConvertsHTMLResponse(t *testing.T) {
+	handler := XHTMLMiddleware(Config{
+		Options:         converter.Options{AutoFix: true},
+		EnableStreaming: true,
+	})(htmlHandler(`<DIV>hi</DIV>`))
+
+	req := httptest.NewRequest(http.MethodGet, "/page.html", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, "application/xhtml+xml") {
+		t.Errorf("Content-Type = %q, want application/xhtml+xml", ct)
+	}
+	if got := rec.Body.String(); !strings.Contains(got, "<div>hi</div>") {
+		t.Errorf("body = %q, want lowercased <div>hi</div>", got)
+	}
+}
+
+func TestXHTMLMiddleware_Streaming_PassesThroughNonHTML(t *testing.T) {
+	handler := XHTMLMiddleware(Config{
+		Options:         converter.Options{AutoFix: true},
+		EnableStreaming: true,
+	})(func() http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}())
+
+	req := httptest.NewRequest(http.MethodGet, "/page.html", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Body.String(); got != `{"ok":true}` {
+		t.Errorf("body = %q, want untouched JSON passthrough", got)
+	}
+}
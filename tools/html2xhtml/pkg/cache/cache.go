@@ -0,0 +1,54 @@
+// pkg/cache/cache.go
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+// Cache хранит уже сконвертированные документы, чтобы повторный запрос с тем же
+// содержимым и теми же опциями не гонял HTML через парсер и правила заново -
+// полезно для пайплайна статического сайта, пересобираемого регулярно, где реально
+// меняется лишь малая часть страниц (см. FileCache, аналог Hugo filecache). Put -
+// best-effort: ошибка записи на диск (нет места, нет прав) не должна останавливать
+// конвертацию, поэтому, как и middleware.Cache.Set, метод не возвращает error.
+type Cache interface {
+	Get(key string) (out []byte, result *converter.Result, ok bool)
+	Put(key string, out []byte, result *converter.Result)
+}
+
+// Key считает ключ кеша как sha256(input || сериализованные опции, влияющие на
+// результат конвертации) в hex. opts.Rules и opts.OnChange не учитываются - это
+// функции/интерфейсы, не детерминированные между запусками процесса; вызывающий
+// код, подмешивающий свои Rules через opts.Rules, должен либо не использовать
+// этот кеш, либо внести версию своего набора правил в key самостоятельно (например,
+// собственным префиксом).
+func Key(input []byte, opts converter.Options) string {
+	h := sha256.New()
+	h.Write(input)
+	fmt.Fprintf(h, "\x00strict=%v autofix=%v preserve=%v validate=%v canonical=%v rewrite=%v",
+		opts.StrictMode, opts.AutoFix, opts.PreserveFormatting, opts.ValidateOnly, opts.Canonical, opts.RewriteRelativeURLs)
+	if opts.BaseURL != nil {
+		fmt.Fprintf(h, " base=%s", opts.BaseURL.String())
+	}
+	writeSortedList(h, "enable", opts.EnableRules)
+	writeSortedList(h, "disable", opts.DisableRules)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeSortedList подмешивает items в h отсортированными, чтобы один и тот же
+// набор EnableRules/DisableRules давал один и тот же ключ независимо от порядка,
+// в котором вызывающий код их собрал (splitCSV, конфиг и т.п.)
+func writeSortedList(h io.Writer, label string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	sorted := append([]string(nil), items...)
+	sort.Strings(sorted)
+	fmt.Fprintf(h, " %s=%v", label, sorted)
+}
@@ -0,0 +1,56 @@
+// pkg/cache/cache_test.go
+package cache
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+func TestKey_SameInputAndOptions_SameKey(t *testing.T) {
+	opts := converter.Options{AutoFix: true, EnableRules: []string{"lowercase-tags"}}
+	k1 := Key([]byte("<p>hi</p>"), opts)
+	k2 := Key([]byte("<p>hi</p>"), opts)
+	if k1 != k2 {
+		t.Fatalf("expected deterministic key, got %q and %q", k1, k2)
+	}
+}
+
+func TestKey_DifferentInput_DifferentKey(t *testing.T) {
+	opts := converter.Options{AutoFix: true}
+	k1 := Key([]byte("<p>hi</p>"), opts)
+	k2 := Key([]byte("<p>bye</p>"), opts)
+	if k1 == k2 {
+		t.Fatalf("expected different keys for different input, got same %q", k1)
+	}
+}
+
+func TestKey_DifferentOptions_DifferentKey(t *testing.T) {
+	input := []byte("<p>hi</p>")
+	k1 := Key(input, converter.Options{AutoFix: true})
+	k2 := Key(input, converter.Options{AutoFix: false})
+	if k1 == k2 {
+		t.Fatalf("expected different keys for different opts.AutoFix, got same %q", k1)
+	}
+}
+
+func TestKey_BaseURL_AffectsKey(t *testing.T) {
+	input := []byte("<a href=\"/x\">x</a>")
+	base1, _ := url.Parse("https://a.example/")
+	base2, _ := url.Parse("https://b.example/")
+	k1 := Key(input, converter.Options{RewriteRelativeURLs: true, BaseURL: base1})
+	k2 := Key(input, converter.Options{RewriteRelativeURLs: true, BaseURL: base2})
+	if k1 == k2 {
+		t.Fatalf("expected different keys for different BaseURL, got same %q", k1)
+	}
+}
+
+func TestKey_RuleListOrder_DoesNotAffectKey(t *testing.T) {
+	input := []byte("<p>hi</p>")
+	k1 := Key(input, converter.Options{EnableRules: []string{"a", "b"}})
+	k2 := Key(input, converter.Options{EnableRules: []string{"b", "a"}})
+	if k1 != k2 {
+		t.Fatalf("expected EnableRules order to not affect key, got %q and %q", k1, k2)
+	}
+}
@@ -0,0 +1,267 @@
+// pkg/cache/filecache.go
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+// DefaultMaxSizeMB - ограничение FileCache по умолчанию, если NewFileCache
+// вызван с maxSizeMB <= 0
+const DefaultMaxSizeMB = 256
+
+// FileCache реализация Cache поверх директории на диске: каждая запись - пара
+// файлов <dir>/<key[:2]>/<key>.xhtml (тело) и <key>.json (остальные поля
+// converter.Result), шардированных по первым двум символам ключа, чтобы не класть
+// сотни тысяч файлов в один каталог (как git objects/ или Hugo filecache). Запись
+// атомарна - сначала во временный файл в том же каталоге, затем os.Rename.
+// Вытеснение - LRU по суммарному размеру .xhtml файлов, ограниченному MaxSizeMB;
+// порядок восстанавливается при старте по mtime файлов, так что он переживает
+// перезапуск процесса.
+type FileCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	curBytes int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type fileCacheItem struct {
+	key  string
+	size int64
+}
+
+// record - содержимое <key>.json: поля converter.Result, кроме самого Output
+// (хранится отдельным файлом, см. outputPath) и Errors (в кеш попадают только
+// успешные результаты - см. Put)
+type record struct {
+	OriginalSize int64
+	FinalSize    int64
+	Changes      []converter.Change
+	Warnings     []string
+	Preloads     []converter.Preload
+	Metadata     converter.Metadata
+}
+
+// NewFileCache создает (при необходимости) каталог dir и открывает файловый кеш с
+// ограничением maxSizeMB; maxSizeMB <= 0 заменяется на DefaultMaxSizeMB.
+func NewFileCache(dir string, maxSizeMB int) (*FileCache, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = DefaultMaxSizeMB
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	fc := &FileCache{
+		dir:      dir,
+		maxBytes: int64(maxSizeMB) * 1024 * 1024,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+	fc.loadIndex()
+	return fc, nil
+}
+
+// loadIndex сканирует dir на уже существующие записи (после перезапуска процесса)
+// и восстанавливает порядок LRU по mtime .xhtml файлов - от старых к новым, чтобы
+// первый же Put не вытеснил недавно использованные записи
+func (fc *FileCache) loadIndex() {
+	type found struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var entries []found
+
+	filepath.Walk(fc.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info == nil || info.IsDir() || filepath.Ext(path) != ".xhtml" {
+			return nil
+		}
+		key := strings.TrimSuffix(filepath.Base(path), ".xhtml")
+		entries = append(entries, found{key: key, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+
+	for _, e := range entries {
+		el := fc.ll.PushFront(&fileCacheItem{key: e.key, size: e.size})
+		fc.items[e.key] = el
+		fc.curBytes += e.size
+	}
+}
+
+func (fc *FileCache) shardDir(key string) string {
+	if len(key) < 2 {
+		return fc.dir
+	}
+	return filepath.Join(fc.dir, key[:2])
+}
+
+func (fc *FileCache) outputPath(key string) string {
+	return filepath.Join(fc.shardDir(key), key+".xhtml")
+}
+
+func (fc *FileCache) recordPath(key string) string {
+	return filepath.Join(fc.shardDir(key), key+".json")
+}
+
+// Get возвращает закешированный вывод и восстановленный *converter.Result, если
+// запись есть на диске. Отсутствующие/повреждённые файлы (индекс и диск разошлись)
+// трактуются как промах кеша, с удалением записи из индекса, а не как ошибка.
+func (fc *FileCache) Get(key string) ([]byte, *converter.Result, bool) {
+	fc.mu.Lock()
+	el, ok := fc.items[key]
+	if ok {
+		fc.ll.MoveToFront(el)
+	}
+	fc.mu.Unlock()
+	if !ok {
+		return nil, nil, false
+	}
+
+	out, err := os.ReadFile(fc.outputPath(key))
+	if err != nil {
+		fc.remove(key)
+		return nil, nil, false
+	}
+
+	recBytes, err := os.ReadFile(fc.recordPath(key))
+	if err != nil {
+		fc.remove(key)
+		return nil, nil, false
+	}
+	var rec record
+	if err := json.Unmarshal(recBytes, &rec); err != nil {
+		fc.remove(key)
+		return nil, nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(fc.outputPath(key), now, now)
+
+	result := &converter.Result{
+		Success:      true,
+		Output:       out,
+		OriginalSize: rec.OriginalSize,
+		FinalSize:    rec.FinalSize,
+		Changes:      rec.Changes,
+		Warnings:     rec.Warnings,
+		Preloads:     rec.Preloads,
+		Metadata:     rec.Metadata,
+	}
+	return out, result, true
+}
+
+// Put сохраняет результат конвертации на диск и вытесняет самые давно
+// использованные записи, пока суммарный размер не уложится в MaxSizeMB.
+// Предполагается, что вызывающий код передает только успешные результаты -
+// неуспешные конвертации кешировать незачем, они и так быстро проваливаются
+// заново на этапе парсинга/валидации.
+func (fc *FileCache) Put(key string, out []byte, result *converter.Result) {
+	shard := fc.shardDir(key)
+	if err := os.MkdirAll(shard, 0o755); err != nil {
+		return
+	}
+	if err := writeFileAtomic(fc.outputPath(key), out); err != nil {
+		return
+	}
+
+	var rec record
+	if result != nil {
+		rec = record{
+			OriginalSize: result.OriginalSize,
+			FinalSize:    result.FinalSize,
+			Changes:      result.Changes,
+			Warnings:     result.Warnings,
+			Preloads:     result.Preloads,
+			Metadata:     result.Metadata,
+		}
+	}
+	recBytes, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(fc.recordPath(key), recBytes); err != nil {
+		return
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if el, ok := fc.items[key]; ok {
+		item := el.Value.(*fileCacheItem)
+		fc.curBytes += int64(len(out)) - item.size
+		item.size = int64(len(out))
+		fc.ll.MoveToFront(el)
+	} else {
+		item := &fileCacheItem{key: key, size: int64(len(out))}
+		fc.items[key] = fc.ll.PushFront(item)
+		fc.curBytes += item.size
+	}
+
+	for fc.curBytes > fc.maxBytes && fc.ll.Len() > 0 {
+		fc.evictOldest()
+	}
+}
+
+// remove удаляет запись из индекса и с диска - вызывается из Get, когда индекс и
+// диск разошлись (файл отсутствует/поврежден)
+func (fc *FileCache) remove(key string) {
+	fc.mu.Lock()
+	if el, ok := fc.items[key]; ok {
+		fc.ll.Remove(el)
+		delete(fc.items, key)
+		fc.curBytes -= el.Value.(*fileCacheItem).size
+	}
+	fc.mu.Unlock()
+
+	os.Remove(fc.outputPath(key))
+	os.Remove(fc.recordPath(key))
+}
+
+// evictOldest вытесняет наименее недавно использованную запись; вызывающий код
+// должен держать fc.mu
+func (fc *FileCache) evictOldest() {
+	el := fc.ll.Back()
+	if el == nil {
+		return
+	}
+	item := el.Value.(*fileCacheItem)
+	fc.ll.Remove(el)
+	delete(fc.items, item.key)
+	fc.curBytes -= item.size
+
+	os.Remove(fc.outputPath(item.key))
+	os.Remove(fc.recordPath(item.key))
+}
+
+var tmpFileCounter int64
+
+// writeFileAtomic пишет data во временный файл рядом с path и атомарно
+// переименовывает его в path - так что параллельный Get никогда не увидит
+// наполовину записанный файл
+func writeFileAtomic(path string, data []byte) error {
+	tmp := fmt.Sprintf("%s.tmp-%d-%d", path, os.Getpid(), atomic.AddInt64(&tmpFileCounter, 1))
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
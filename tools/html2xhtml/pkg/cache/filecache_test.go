@@ -0,0 +1,88 @@
+// pkg/cache/filecache_test.go
+package cache
+
+import (
+	"testing"
+
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+func TestFileCache_PutThenGet_RoundTrips(t *testing.T) {
+	fc, err := NewFileCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+
+	result := &converter.Result{
+		Success:      true,
+		FinalSize:    9,
+		OriginalSize: 10,
+		Changes:      []converter.Change{{Type: converter.ChangeUppercaseTag, Message: "lowercased"}},
+		Warnings:     []string{"warn"},
+	}
+	fc.Put("key1", []byte("<p>hi</p>"), result)
+
+	out, got, ok := fc.Get("key1")
+	if !ok {
+		t.Fatal("expected cache hit after Put")
+	}
+	if string(out) != "<p>hi</p>" {
+		t.Fatalf("output = %q", out)
+	}
+	if !got.Success || got.FinalSize != 9 || got.OriginalSize != 10 {
+		t.Fatalf("unexpected restored result: %+v", got)
+	}
+	if len(got.Changes) != 1 || got.Changes[0].Message != "lowercased" {
+		t.Fatalf("changes not preserved: %+v", got.Changes)
+	}
+}
+
+func TestFileCache_Get_MissForUnknownKey(t *testing.T) {
+	fc, err := NewFileCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+
+	if _, _, ok := fc.Get("missing"); ok {
+		t.Fatal("expected cache miss for unknown key")
+	}
+}
+
+func TestFileCache_EvictsLeastRecentlyUsedOverLimit(t *testing.T) {
+	dir := t.TempDir()
+	// 1 MiB limit, but NewFileCache rounds to whole MiB, so use byte-level control
+	// by writing entries larger than the limit allows to coexist.
+	fc, err := NewFileCache(dir, 1)
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+
+	big := make([]byte, 700*1024)
+	fc.Put("a", big, &converter.Result{Success: true})
+	fc.Put("b", big, &converter.Result{Success: true})
+
+	if _, _, ok := fc.Get("a"); ok {
+		t.Fatal("expected \"a\" to be evicted once total size exceeded MaxSizeMB")
+	}
+	if _, _, ok := fc.Get("b"); !ok {
+		t.Fatal("expected \"b\" to remain cached")
+	}
+}
+
+func TestFileCache_ReopeningPreservesEntries(t *testing.T) {
+	dir := t.TempDir()
+	fc1, err := NewFileCache(dir, 0)
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+	fc1.Put("key1", []byte("<p>hi</p>"), &converter.Result{Success: true, FinalSize: 9})
+
+	fc2, err := NewFileCache(dir, 0)
+	if err != nil {
+		t.Fatalf("NewFileCache (reopen): %v", err)
+	}
+	out, _, ok := fc2.Get("key1")
+	if !ok || string(out) != "<p>hi</p>" {
+		t.Fatalf("expected entry to survive reopening the same dir, got %q ok=%v", out, ok)
+	}
+}
@@ -0,0 +1,131 @@
+// pkg/converter/canonical.go
+package converter
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Canonicalize парсит input и сериализует его в канонической форме: атрибуты
+// отсортированы лексикографически по (Namespace, Key), значения атрибутов всегда
+// в двойных кавычках с экранированием в числовые сущности, void-элементы пишутся
+// как <tag/> без пробела, комментарии и doctype отбрасываются. Два документа с
+// одинаковым DOM всегда дают побайтово одинаковый результат, в отличие от сырого
+// HTML, где пробелы, порядок атрибутов и способ кодирования сущностей не значимы,
+// но меняют байты - это и нужно как вход для хеширования/цифровой подписи.
+func Canonicalize(input []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(input))
+	if err != nil {
+		return nil, NewError(ErrParseFailed, "failed to parse HTML", err)
+	}
+
+	var buf bytes.Buffer
+	if err := renderCanonicalNode(doc, &buf); err != nil {
+		return nil, NewError(ErrConversionFailed, "failed to render canonical XHTML", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderCanonicalNode сериализует узел и его детей в канонической форме. Используется
+// и как Options.Canonical путь в DefaultConverter.renderXHTML, и из Canonicalize -
+// сама функция не зависит от состояния конвертера.
+func renderCanonicalNode(n *html.Node, w io.Writer) error {
+	switch n.Type {
+	case html.DocumentNode:
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if err := renderCanonicalNode(child, w); err != nil {
+				return err
+			}
+		}
+	case html.ElementNode:
+		if _, err := io.WriteString(w, "<"+n.Data); err != nil {
+			return err
+		}
+
+		attrs := append([]html.Attribute(nil), n.Attr...)
+		sort.Slice(attrs, func(i, j int) bool {
+			if attrs[i].Namespace != attrs[j].Namespace {
+				return attrs[i].Namespace < attrs[j].Namespace
+			}
+			return attrs[i].Key < attrs[j].Key
+		})
+		for _, attr := range attrs {
+			if _, err := io.WriteString(w, " "+attr.Key+`="`+escapeCanonicalAttr(attr.Val)+`"`); err != nil {
+				return err
+			}
+		}
+
+		if isVoidElement(n.Data) {
+			_, err := io.WriteString(w, "/>")
+			return err
+		}
+		if _, err := io.WriteString(w, ">"); err != nil {
+			return err
+		}
+
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if err := renderCanonicalNode(child, w); err != nil {
+				return err
+			}
+		}
+
+		_, err := io.WriteString(w, "</"+n.Data+">")
+		return err
+	case html.TextNode:
+		_, err := io.WriteString(w, escapeCanonicalText(n.Data))
+		return err
+	case html.CommentNode, html.DoctypeNode:
+		// Комментарии (включая "bogus comment" для любых "<? ... >"-конструкций,
+		// которые HTML5-парсер трактует как комментарии, а не processing
+		// instructions) и doctype не участвуют в каноническом представлении -
+		// подписывается только содержимое документа.
+	}
+	return nil
+}
+
+// escapeCanonicalAttr экранирует значение атрибута в числовые сущности: &, <, "
+// и \r - минимальный набор, необходимый чтобы значение оставалось валидным внутри
+// двойных кавычек независимо от содержимого
+func escapeCanonicalAttr(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '&':
+			b.WriteString("&#38;")
+		case '<':
+			b.WriteString("&#60;")
+		case '"':
+			b.WriteString("&#34;")
+		case '\r':
+			b.WriteString("&#13;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// escapeCanonicalText нормализует текстовые узлы: CRLF и одиночный CR схлопываются
+// в LF, как того требует каноническая форма, а & и < по-прежнему экранируются
+// числовыми сущностями, чтобы текст оставался валидным XHTML
+func escapeCanonicalText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '&':
+			b.WriteString("&#38;")
+		case '<':
+			b.WriteString("&#60;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+// pkg/converter/canonical_test.go
+package converter
+
+import "testing"
+
+func TestCanonicalize_SortsAttributesLexicographically(t *testing.T) {
+	got, err := Canonicalize([]byte(`<div id="x" class="y" data-a="1"></div>`))
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div class="y" data-a="1" id="x"></div></body></html>`
+	if string(got) != want {
+		t.Errorf("Canonicalize() = %s, want %s", got, want)
+	}
+}
+
+func TestCanonicalize_AttributeOrderDoesNotChangeOutput(t *testing.T) {
+	a, err := Canonicalize([]byte(`<img src="x.png" alt="a" width="10">`))
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+	b, err := Canonicalize([]byte(`<img width="10" src="x.png" alt="a">`))
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+
+	if string(a) != string(b) {
+		t.Errorf("Canonicalize() not stable across attribute order: %s vs %s", a, b)
+	}
+}
+
+func TestCanonicalize_VoidElementHasNoSpaceBeforeSlash(t *testing.T) {
+	got, err := Canonicalize([]byte(`<br>`))
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+
+	want := `<html><head></head><body><br/></body></html>`
+	if string(got) != want {
+		t.Errorf("Canonicalize() = %s, want %s", got, want)
+	}
+}
+
+func TestCanonicalize_EscapesAttributeValuesAsNumericEntities(t *testing.T) {
+	got, err := Canonicalize([]byte("<div title='a &amp; b &lt; c \"d\"'></div>"))
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div title="a &#38; b &#60; c &#34;d&#34;"></div></body></html>`
+	if string(got) != want {
+		t.Errorf("Canonicalize() = %s, want %s", got, want)
+	}
+}
+
+func TestCanonicalize_NormalizesLineEndingsInText(t *testing.T) {
+	got, err := Canonicalize([]byte("<p>a\r\nb\rc</p>"))
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+
+	want := "<html><head></head><body><p>a\nb\nc</p></body></html>"
+	if string(got) != want {
+		t.Errorf("Canonicalize() = %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalize_StripsComments(t *testing.T) {
+	got, err := Canonicalize([]byte(`<div><!-- secret -->hi</div>`))
+	if err != nil {
+		t.Fatalf("Canonicalize() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div>hi</div></body></html>`
+	if string(got) != want {
+		t.Errorf("Canonicalize() = %s, want %s", got, want)
+	}
+}
+
+func TestDefaultConverter_RenderXHTML_CanonicalOption(t *testing.T) {
+	conv := New()
+	result, err := conv.Convert([]byte(`<div b="2" a="1"></div>`), Options{Canonical: true})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div a="1" b="2"></div></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("Convert() output = %s, want %s", result.Output, want)
+	}
+}
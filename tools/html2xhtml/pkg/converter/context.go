@@ -39,6 +39,14 @@ func (c *DefaultConverter) ConvertWithContext(ctx context.Context, input []byte,
 		result.Errors = append(result.Errors, err)
 	}
 
+	if doc != nil {
+		if opts.RewriteRelativeURLs && opts.BaseURL != nil {
+			rewriteRelativeURLs(doc, opts.BaseURL)
+		}
+		result.Preloads = ExtractPreloads(doc)
+		result.Metadata = ExtractMetadata(doc)
+	}
+
 	// Проверка отмены после парсинга
 	select {
 	case <-ctx.Done():
@@ -146,7 +154,8 @@ func (c *DefaultConverter) fixNodeWithContext(ctx context.Context, n *html.Node,
 	default:
 	}
 
-	// Базовое исправление (переиспользуем существующую логику)
+	// Базовое исправление (переиспользуем существующую логику, включая обход,
+	// устойчивый к удалению узла правилами - см. DefaultConverter.fixNode)
 	c.fixNode(n, result, opts)
 
 	return nil
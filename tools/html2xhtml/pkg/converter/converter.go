@@ -7,6 +7,7 @@ import (
 	"errors"
 	"golang.org/x/net/html"
 	"io"
+	"net/url"
 	"strings"
 )
 
@@ -16,6 +17,18 @@ type Converter interface {
 	Validate(input []byte) error
 	ConvertWithContext(ctx context.Context, input []byte, opts Options) (*Result, error)
 	ValidateWithContext(ctx context.Context, input []byte) error
+	// ConvertStream конвертирует HTML → XHTML потоково (см. StreamConverter), не
+	// буферизуя весь DOM в память - быстрый путь для AutoFix-режима на больших
+	// документах. Для валидационных режимов (StrictMode без AutoFix, ValidateOnly)
+	// по-прежнему используйте Convert/ConvertWithContext.
+	ConvertStream(ctx context.Context, r io.Reader, w io.Writer, opts Options) (*Result, error)
+
+	// RegisterRule добавляет rule к набору правил, с которым уже работает конвертер,
+	// без пересоздания его через NewWithRules - удобно, когда правила собираются
+	// динамически (например, из конфига или флагов cmd/shp-convert). Не потокобезопасен
+	// относительно одновременных вызовов Convert/ConvertWithContext - регистрируйте
+	// все правила на этапе настройки, до начала конвертаций.
+	RegisterRule(rule Rule)
 }
 
 // Options опции конвертации
@@ -25,6 +38,35 @@ type Options struct {
 	Verbose            bool // Детальные логи
 	PreserveFormatting bool // Сохранять форматирование
 	ValidateOnly       bool // Только валидация, без конвертации
+	Canonical          bool // Канонический рендеринг (см. renderCanonicalNode) вместо обычного renderXHTML - для хеширования/цифровой подписи
+
+	// OnChange, если задан, вызывается ConvertStream (StreamConverter) для каждого
+	// Change вместо накопления их в Result.Changes - чтобы на больших документах не
+	// держать в памяти список изменений размером с документ; Result.Changes тогда
+	// остается пустым. Convert/ConvertWithContext OnChange не вызывают и всегда
+	// заполняют Result.Changes.
+	OnChange func(Change)
+
+	// RewriteRelativeURLs включает резолв относительных href/src и URL-значных
+	// content (og:image, twitter:image и т.п.) в абсолютные относительно BaseURL -
+	// нужно, когда конвертер используется как стадия нормализации HTML в пайплайне
+	// превью-ссылок/архивации, который потребляет Result.Metadata. Требует BaseURL.
+	RewriteRelativeURLs bool
+	BaseURL             *url.URL
+
+	// Rules - дополнительные правила автоисправления поверх тех, с которыми
+	// создан конвертер (см. New/NewWithRules) - для политик, нужных только на
+	// конкретный вызов Convert/ConvertWithContext, а не на все время жизни
+	// конвертера (например, "в этом запросе вырезать <script>").
+	Rules []Rule
+
+	// EnableRules, если не пуст, ограничивает применяемые правила только теми, чей
+	// Rule.ID() в списке - остальные правила конвертера и opts.Rules пропускаются.
+	// DisableRules исключает перечисленные ID даже если они прошли через EnableRules.
+	// Оба матчатся против Rule.ID(), а не имени Go-типа - см. cmd/shp-convert
+	// -rule-enable/-rule-disable.
+	EnableRules  []string
+	DisableRules []string
 }
 
 // Result результат конвертации
@@ -36,6 +78,8 @@ type Result struct {
 	Changes      []Change
 	Errors       []error
 	Warnings     []string
+	Preloads     []Preload
+	Metadata     Metadata
 }
 
 // Change описание изменения
@@ -45,6 +89,8 @@ type Change struct {
 	Message  string
 	Original string
 	Fixed    string
+	Line     int    // 1-based номер строки во входном документе; заполняется только ConvertStream (см. stream.go), 0 если недоступен
+	RuleID   string // Rule.ID() правила, которое внесло это изменение; пусто для изменений, не связанных с Rule (например, из PreValidator)
 }
 
 type ChangeType int
@@ -55,18 +101,51 @@ const (
 	ChangeUppercaseTag
 	ChangeInvalidNesting
 	ChangeMissingNamespace
+	ChangeStrippedElement
+	ChangeDroppedAttr
+	ChangeRewrittenTag
+	ChangeMissingRequiredAttr
 )
 
+// String человекочитаемое имя типа изменения, используется как метка метрик
+// (например, shp_changes_total{type})
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeUnclosedTag:
+		return "unclosed_tag"
+	case ChangeUnquotedAttr:
+		return "unquoted_attr"
+	case ChangeUppercaseTag:
+		return "uppercase_tag"
+	case ChangeInvalidNesting:
+		return "invalid_nesting"
+	case ChangeMissingNamespace:
+		return "missing_namespace"
+	case ChangeStrippedElement:
+		return "stripped_element"
+	case ChangeDroppedAttr:
+		return "dropped_attr"
+	case ChangeRewrittenTag:
+		return "rewritten_tag"
+	case ChangeMissingRequiredAttr:
+		return "missing_required_attr"
+	default:
+		return "unknown"
+	}
+}
+
 // DefaultConverter реализация конвертера
 type DefaultConverter struct{
 	metrics      Metrics
 	preValidator *PreValidator
+	rules        []Rule
 }
 
 func New() Converter {
 	return &DefaultConverter{
 		metrics:      &NoOpMetrics{},
 		preValidator: NewPreValidator(),
+		rules:        DefaultRules(),
 	}
 }
 
@@ -74,6 +153,22 @@ func NewWithMetrics(metrics Metrics) Converter {
 	return &DefaultConverter{
 		metrics:      metrics,
 		preValidator: NewPreValidator(),
+		rules:        DefaultRules(),
+	}
+}
+
+// NewWithRules создает конвертер с собственным набором правил автоисправления
+// вместо DefaultRules(). Пригодится, чтобы добавить свои правила (например, убрать
+// javascript: URL) поверх или вместо встроенных - для этого передайте
+// append(DefaultRules(), myRule{}).
+func NewWithRules(metrics Metrics, rules []Rule) Converter {
+	if metrics == nil {
+		metrics = &NoOpMetrics{}
+	}
+	return &DefaultConverter{
+		metrics:      metrics,
+		preValidator: NewPreValidator(),
+		rules:        rules,
 	}
 }
 
@@ -127,8 +222,20 @@ func (c *DefaultConverter) Convert(input []byte, opts Options) (*Result, error)
 		result.Errors = append(result.Errors, err)
 	}
 
-	// ШАГ 3: Валидация структуры (после парсинга)
-	if !opts.AutoFix {
+	if doc != nil {
+		if opts.RewriteRelativeURLs && opts.BaseURL != nil {
+			rewriteRelativeURLs(doc, opts.BaseURL)
+		}
+		result.Preloads = ExtractPreloads(doc)
+		result.Metadata = ExtractMetadata(doc)
+	}
+
+	// ШАГ 3: Применение auto-fix правил (если AutoFix) или валидация структуры
+	if opts.AutoFix {
+		if doc != nil {
+			c.fixNode(doc, result, opts)
+		}
+	} else {
 		if err := c.validateNode(doc, result); err != nil {
 			if c.metrics != nil {
 				c.metrics.RecordError(ErrValidationFailed)
@@ -162,6 +269,18 @@ func (c *DefaultConverter) Convert(input []byte, opts Options) (*Result, error)
 	return result, nil
 }
 
+// ConvertStream делегирует на StreamConverter, переиспользуя метрики конвертера -
+// см. Converter.ConvertStream
+func (c *DefaultConverter) ConvertStream(ctx context.Context, r io.Reader, w io.Writer, opts Options) (*Result, error) {
+	sc := NewStreamConverter(c.metrics)
+	return sc.Convert(ctx, r, w, opts)
+}
+
+// RegisterRule - см. Converter.RegisterRule
+func (c *DefaultConverter) RegisterRule(rule Rule) {
+	c.rules = append(c.rules, rule)
+}
+
 func (c *DefaultConverter) Validate(input []byte) error {
 	// Пре-валидация: проверка исходного HTML
 	issues := c.preValidator.Validate(string(input))
@@ -211,36 +330,83 @@ func (c *DefaultConverter) validateNode(n *html.Node, result *Result) error {
 	return nil
 }
 
-// fixNode исправляет узел для XHTML
+// fixNode исправляет узел для XHTML, применяя к нему c.rules (по умолчанию
+// DefaultRules(), либо набор, переданный в NewWithRules) плюс opts.Rules на
+// этот конкретный вызов. Обход детей использует следующий sibling, захваченный
+// до рекурсии, потому что правило может удалить текущий узел из дерева
+// (см. RuleStripTag) - после удаления его NextSibling уже не указывает дальше
 func (c *DefaultConverter) fixNode(n *html.Node, result *Result, opts Options) {
-	if n.Type == html.ElementNode {
-		// Lowercase тегов
-		if n.Data != strings.ToLower(n.Data) {
-			result.Changes = append(result.Changes, Change{
-				Type:     ChangeUppercaseTag,
-				Message:  "Converted tag to lowercase",
-				Original: n.Data,
-				Fixed:    strings.ToLower(n.Data),
-			})
-			n.Data = strings.ToLower(n.Data)
-		}
+	c.applyRules(n, result, opts)
+
+	child := n.FirstChild
+	for child != nil {
+		next := child.NextSibling
+		c.fixNode(child, result, opts)
+		child = next
+	}
+}
 
-		// Lowercase атрибутов
-		for i := range n.Attr {
-			if n.Attr[i].Key != strings.ToLower(n.Attr[i].Key) {
-				n.Attr[i].Key = strings.ToLower(n.Attr[i].Key)
+// applyRules прогоняет по узлу c.rules, затем opts.Rules, записывая Change в
+// result.Changes и в метрики для каждого сработавшего правила. Правило с ID,
+// отсеянным ruleAllowed (см. Options.EnableRules/DisableRules), пропускается,
+// как будто его не было зарегистрировано. Ошибка из Rule.Apply не прерывает
+// конвертацию - она добавляется в result.Warnings, а Change для этого
+// срабатывания не засчитывается
+func (c *DefaultConverter) applyRules(n *html.Node, result *Result, opts Options) {
+	for _, rules := range [][]Rule{c.rules, opts.Rules} {
+		for _, rule := range rules {
+			if !ruleAllowed(rule.ID(), opts) {
+				continue
+			}
+			if !rule.Match(n) {
+				continue
+			}
+			change, err := rule.Apply(n)
+			if err != nil {
+				result.Warnings = append(result.Warnings, err.Error())
+				continue
+			}
+			change.RuleID = rule.ID()
+			result.Changes = append(result.Changes, change)
+			if c.metrics != nil {
+				c.metrics.RecordRuleApplied(rule.ID())
 			}
 		}
 	}
+}
 
-	// Рекурсия
-	for child := n.FirstChild; child != nil; child = child.NextSibling {
-		c.fixNode(child, result, opts)
+// ruleAllowed сообщает, разрешено ли применять правило с данным id с учетом
+// Options.EnableRules/DisableRules: если EnableRules не пуст, id должен в нем
+// присутствовать; DisableRules исключает id в любом случае, даже если он прошел
+// EnableRules. Пустые EnableRules/DisableRules разрешают все правила (поведение
+// по умолчанию, как до появления реестра)
+func ruleAllowed(id string, opts Options) bool {
+	if len(opts.EnableRules) > 0 {
+		enabled := false
+		for _, e := range opts.EnableRules {
+			if e == id {
+				enabled = true
+				break
+			}
+		}
+		if !enabled {
+			return false
+		}
 	}
+	for _, d := range opts.DisableRules {
+		if d == id {
+			return false
+		}
+	}
+	return true
 }
 
 // renderXHTML сериализует в XHTML формат
 func (c *DefaultConverter) renderXHTML(n *html.Node, w io.Writer, opts Options) error {
+	if opts.Canonical {
+		return renderCanonicalNode(n, w)
+	}
+
 	switch n.Type {
 	case html.DocumentNode:
 		for child := n.FirstChild; child != nil; child = child.NextSibling {
@@ -283,7 +449,10 @@ func (c *DefaultConverter) renderXHTML(n *html.Node, w io.Writer, opts Options)
 	return nil
 }
 
-// isVoidElement проверяет является ли тег void элементом
+// isVoidElement проверяет является ли тег void элементом. Сравнение регистронезависимо
+// (strings.ToLower), потому что StreamConverter.writeTag может передать тег в
+// исходном регистре, если правило lowercase-tags отключено через Options.DisableRules -
+// самозакрытие void-элементов (close-voids) не зависит от того, нормализован ли регистр
 func isVoidElement(tag string) bool {
 	voidElements := map[string]bool{
 		"area": true, "base": true, "br": true, "col": true,
@@ -291,5 +460,5 @@ func isVoidElement(tag string) bool {
 		"link": true, "meta": true, "param": true, "source": true,
 		"track": true, "wbr": true,
 	}
-	return voidElements[tag]
+	return voidElements[strings.ToLower(tag)]
 }
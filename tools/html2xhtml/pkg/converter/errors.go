@@ -16,6 +16,27 @@ const (
 	ErrInvalidInput
 )
 
+// String человекочитаемое имя кода ошибки, используется как метка метрик
+// (например, shp_errors_total{code})
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrParseFailed:
+		return "parse_failed"
+	case ErrValidationFailed:
+		return "validation_failed"
+	case ErrConversionFailed:
+		return "conversion_failed"
+	case ErrTimeout:
+		return "timeout"
+	case ErrContextCanceled:
+		return "context_canceled"
+	case ErrInvalidInput:
+		return "invalid_input"
+	default:
+		return "unknown"
+	}
+}
+
 // Error структурированная ошибка
 type Error struct {
 	Code    ErrorCode
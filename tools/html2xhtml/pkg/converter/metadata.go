@@ -0,0 +1,65 @@
+// pkg/converter/metadata.go
+package converter
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Metadata собирает метаданные страницы, извлеченные из <meta>/<link> при
+// конвертации - нужен потребителям Result (пайплайны превью-ссылок, архивации),
+// которым не хочется парсить HTML повторно отдельным проходом
+type Metadata struct {
+	OpenGraph map[string]string // <meta property="og:*" content="...">, ключ без префикса "og:"
+	Twitter   map[string]string // <meta name="twitter:*" content="...">, ключ без префикса "twitter:"
+	Standard  map[string]string // прочие <meta name="..." content="...">
+	Links     []LinkRel         // <link rel="..." href="...">
+}
+
+// LinkRel одна запись <link rel=... href=...>
+type LinkRel struct {
+	Rel  string
+	Href string
+}
+
+// ExtractMetadata сканирует уже распарсенный документ на <meta> и <link> теги
+// и возвращает найденные метаданные. Порядок Links соответствует порядку
+// появления в документе
+func ExtractMetadata(doc *html.Node) Metadata {
+	meta := Metadata{
+		OpenGraph: make(map[string]string),
+		Twitter:   make(map[string]string),
+		Standard:  make(map[string]string),
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "meta":
+				content := nodeAttr(n, "content")
+				if property := nodeAttr(n, "property"); strings.HasPrefix(property, "og:") {
+					meta.OpenGraph[strings.TrimPrefix(property, "og:")] = content
+				} else if name := nodeAttr(n, "name"); strings.HasPrefix(name, "twitter:") {
+					meta.Twitter[strings.TrimPrefix(name, "twitter:")] = content
+				} else if name != "" {
+					meta.Standard[name] = content
+				}
+			case "link":
+				if rel := nodeAttr(n, "rel"); rel != "" {
+					if href := nodeAttr(n, "href"); href != "" {
+						meta.Links = append(meta.Links, LinkRel{Rel: rel, Href: href})
+					}
+				}
+			}
+		}
+
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	return meta
+}
@@ -0,0 +1,102 @@
+// pkg/converter/metadata_test.go
+package converter
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestExtractMetadata(t *testing.T) {
+	input := `<html><head>
+		<meta property="og:title" content="Example">
+		<meta property="og:image" content="/img.png">
+		<meta name="twitter:card" content="summary">
+		<meta name="description" content="an example page">
+		<link rel="canonical" href="/canonical">
+		<link rel="icon" href="/favicon.ico">
+	</head><body></body></html>`
+
+	doc, err := html.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("html.Parse() error = %v", err)
+	}
+
+	meta := ExtractMetadata(doc)
+
+	if meta.OpenGraph["title"] != "Example" {
+		t.Errorf("OpenGraph[title] = %q, want %q", meta.OpenGraph["title"], "Example")
+	}
+	if meta.OpenGraph["image"] != "/img.png" {
+		t.Errorf("OpenGraph[image] = %q, want %q", meta.OpenGraph["image"], "/img.png")
+	}
+	if meta.Twitter["card"] != "summary" {
+		t.Errorf("Twitter[card] = %q, want %q", meta.Twitter["card"], "summary")
+	}
+	if meta.Standard["description"] != "an example page" {
+		t.Errorf("Standard[description] = %q, want %q", meta.Standard["description"], "an example page")
+	}
+
+	want := []LinkRel{{Rel: "canonical", Href: "/canonical"}, {Rel: "icon", Href: "/favicon.ico"}}
+	if len(meta.Links) != len(want) {
+		t.Fatalf("Links = %+v, want %+v", meta.Links, want)
+	}
+	for i := range meta.Links {
+		if meta.Links[i] != want[i] {
+			t.Errorf("Links[%d] = %+v, want %+v", i, meta.Links[i], want[i])
+		}
+	}
+}
+
+func TestRewriteRelativeURLs(t *testing.T) {
+	input := `<html><head>
+		<link rel="stylesheet" href="/style.css">
+		<meta property="og:image" content="/img.png">
+		<meta property="og:title" content="Not a URL">
+	</head><body><img src="logo.png"></body></html>`
+
+	doc, err := html.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("html.Parse() error = %v", err)
+	}
+
+	base, err := url.Parse("https://example.com/pages/index.html")
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	rewriteRelativeURLs(doc, base)
+	meta := ExtractMetadata(doc)
+	preloads := ExtractPreloads(doc)
+
+	if meta.OpenGraph["image"] != "https://example.com/img.png" {
+		t.Errorf("OpenGraph[image] = %q, want absolute URL", meta.OpenGraph["image"])
+	}
+	if meta.OpenGraph["title"] != "Not a URL" {
+		t.Errorf("OpenGraph[title] = %q, want unchanged", meta.OpenGraph["title"])
+	}
+
+	if len(preloads) != 2 {
+		t.Fatalf("ExtractPreloads() = %+v, want 2 entries", preloads)
+	}
+	if preloads[0].URL != "https://example.com/style.css" {
+		t.Errorf("preloads[0].URL = %q, want absolute URL", preloads[0].URL)
+	}
+	if preloads[1].URL != "https://example.com/pages/logo.png" {
+		t.Errorf("preloads[1].URL = %q, want absolute URL resolved against base", preloads[1].URL)
+	}
+}
+
+func TestDefaultConverter_Convert_RewriteRelativeURLsRequiresBaseURL(t *testing.T) {
+	conv := New()
+	result, err := conv.Convert([]byte(`<img src="logo.png">`), Options{RewriteRelativeURLs: true})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	if len(result.Preloads) != 1 || result.Preloads[0].URL != "logo.png" {
+		t.Errorf("Preloads = %+v, want unchanged relative URL when BaseURL is nil", result.Preloads)
+	}
+}
@@ -12,6 +12,7 @@ type Metrics interface {
 	RecordConversion(duration time.Duration, inputSize, outputSize int64)
 	RecordError(errorType ErrorCode)
 	RecordChange(changeType ChangeType)
+	RecordRuleApplied(rule string)
 	GetStats() ConversionStats
 	Reset()
 }
@@ -26,6 +27,7 @@ type ConversionStats struct {
 	TotalBytesOutput      int64
 	ChangesApplied        map[ChangeType]int64
 	ErrorsByType          map[ErrorCode]int64
+	RulesApplied          map[string]int64
 }
 
 // DefaultMetrics реализация метрик
@@ -39,12 +41,14 @@ type DefaultMetrics struct {
 	totalBytesOutput      int64
 	changesApplied        map[ChangeType]int64
 	errorsByType          map[ErrorCode]int64
+	rulesApplied          map[string]int64
 }
 
 func NewMetrics() Metrics {
 	return &DefaultMetrics{
 		changesApplied: make(map[ChangeType]int64),
 		errorsByType:   make(map[ErrorCode]int64),
+		rulesApplied:   make(map[string]int64),
 	}
 }
 
@@ -71,6 +75,12 @@ func (m *DefaultMetrics) RecordChange(changeType ChangeType) {
 	m.mu.Unlock()
 }
 
+func (m *DefaultMetrics) RecordRuleApplied(rule string) {
+	m.mu.Lock()
+	m.rulesApplied[rule]++
+	m.mu.Unlock()
+}
+
 func (m *DefaultMetrics) GetStats() ConversionStats {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -92,6 +102,11 @@ func (m *DefaultMetrics) GetStats() ConversionStats {
 		errors[k] = v
 	}
 
+	rules := make(map[string]int64)
+	for k, v := range m.rulesApplied {
+		rules[k] = v
+	}
+
 	return ConversionStats{
 		TotalConversions:      atomic.LoadInt64(&m.totalConversions),
 		SuccessfulConversions: atomic.LoadInt64(&m.successfulConversions),
@@ -101,6 +116,7 @@ func (m *DefaultMetrics) GetStats() ConversionStats {
 		TotalBytesOutput:      atomic.LoadInt64(&m.totalBytesOutput),
 		ChangesApplied:        changes,
 		ErrorsByType:          errors,
+		RulesApplied:          rules,
 	}
 }
 
@@ -117,6 +133,7 @@ func (m *DefaultMetrics) Reset() {
 
 	m.changesApplied = make(map[ChangeType]int64)
 	m.errorsByType = make(map[ErrorCode]int64)
+	m.rulesApplied = make(map[string]int64)
 }
 
 // NoOpMetrics заглушка для отключения метрик
@@ -125,10 +142,12 @@ type NoOpMetrics struct{}
 func (m *NoOpMetrics) RecordConversion(duration time.Duration, inputSize, outputSize int64) {}
 func (m *NoOpMetrics) RecordError(errorType ErrorCode)                                      {}
 func (m *NoOpMetrics) RecordChange(changeType ChangeType)                                   {}
+func (m *NoOpMetrics) RecordRuleApplied(rule string)                                        {}
 func (m *NoOpMetrics) GetStats() ConversionStats {
 	return ConversionStats{
 		ChangesApplied: make(map[ChangeType]int64),
 		ErrorsByType:   make(map[ErrorCode]int64),
+		RulesApplied:   make(map[string]int64),
 	}
 }
 func (m *NoOpMetrics) Reset() {}
@@ -0,0 +1,58 @@
+// pkg/converter/preload.go
+package converter
+
+import "golang.org/x/net/html"
+
+// Preload описывает ресурс, который клиент может начать загружать до того как
+// получит весь документ - заголовком "103 Early Hints" или через HTTP/2 Server Push
+type Preload struct {
+	URL  string // значение href/src из документа; абсолютное, только если его уже разрезолвил Options.RewriteRelativeURLs
+	As   string // значение для Link: rel=preload; as=..., например "style", "script", "image"
+	Type string // Content-Type ресурса, если известен (атрибут type тега)
+}
+
+// ExtractPreloads сканирует уже распарсенный документ на <link rel="stylesheet">,
+// <script src=...> и <img src=...> и возвращает найденные ресурсы в порядке
+// появления в документе
+func ExtractPreloads(doc *html.Node) []Preload {
+	var preloads []Preload
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "link":
+				if nodeAttr(n, "rel") == "stylesheet" {
+					if href := nodeAttr(n, "href"); href != "" {
+						preloads = append(preloads, Preload{URL: href, As: "style", Type: nodeAttr(n, "type")})
+					}
+				}
+			case "script":
+				if src := nodeAttr(n, "src"); src != "" {
+					preloads = append(preloads, Preload{URL: src, As: "script", Type: nodeAttr(n, "type")})
+				}
+			case "img":
+				if src := nodeAttr(n, "src"); src != "" {
+					preloads = append(preloads, Preload{URL: src, As: "image"})
+				}
+			}
+		}
+
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	return preloads
+}
+
+// nodeAttr возвращает значение атрибута key узла n, либо "" если его нет
+func nodeAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
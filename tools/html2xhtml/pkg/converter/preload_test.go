@@ -0,0 +1,76 @@
+// pkg/converter/preload_test.go
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestExtractPreloads(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Preload
+	}{
+		{
+			name:  "stylesheet link",
+			input: `<html><head><link rel="stylesheet" href="/style.css"></head><body></body></html>`,
+			want:  []Preload{{URL: "/style.css", As: "style"}},
+		},
+		{
+			name:  "script with src",
+			input: `<html><head><script src="/app.js" type="text/javascript"></script></head><body></body></html>`,
+			want:  []Preload{{URL: "/app.js", As: "script", Type: "text/javascript"}},
+		},
+		{
+			name:  "image with src",
+			input: `<html><body><img src="/logo.png"></body></html>`,
+			want:  []Preload{{URL: "/logo.png", As: "image"}},
+		},
+		{
+			name:  "link without rel=stylesheet is ignored",
+			input: `<html><head><link rel="icon" href="/favicon.ico"></head><body></body></html>`,
+			want:  nil,
+		},
+		{
+			name:  "link without href is ignored",
+			input: `<html><head><link rel="stylesheet"></head><body></body></html>`,
+			want:  nil,
+		},
+		{
+			name:  "script without src is ignored",
+			input: `<html><head><script>console.log(1)</script></head><body></body></html>`,
+			want:  nil,
+		},
+		{
+			name:  "preserves document order across tags",
+			input: `<html><head><link rel="stylesheet" href="/a.css"><script src="/b.js"></script></head><body><img src="/c.png"></body></html>`,
+			want: []Preload{
+				{URL: "/a.css", As: "style"},
+				{URL: "/b.js", As: "script"},
+				{URL: "/c.png", As: "image"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("html.Parse() error = %v", err)
+			}
+
+			got := ExtractPreloads(doc)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ExtractPreloads() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ExtractPreloads()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
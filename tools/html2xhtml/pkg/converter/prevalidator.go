@@ -4,30 +4,31 @@ package converter
 import (
 	"regexp"
 	"strings"
+
+	"golang.org/x/net/html"
 )
 
-// PreValidator проверяет HTML до парсинга для обнаружения нарушений XHTML
+// PreValidator проверяет HTML до парсинга для обнаружения нарушений XHTML.
+// Работает поверх html.Tokenizer вместо регулярных выражений по всему документу:
+// это дает точные Line/Column для каждой проблемы (регулярки применяются только
+// к сырым байтам текущего тега) и позволяет отслеживать вложенность через стек
+// открытых элементов.
 type PreValidator struct {
-	uppercaseTagRe    *regexp.Regexp
-	uppercaseAttrRe   *regexp.Regexp
-	unquotedAttrRe    *regexp.Regexp
-	unclosedVoidRe    *regexp.Regexp
+	uppercaseTagRe  *regexp.Regexp
+	uppercaseAttrRe *regexp.Regexp
+	unquotedAttrRe  *regexp.Regexp
 }
 
 func NewPreValidator() *PreValidator {
 	return &PreValidator{
-		// Теги в uppercase: <HTML>, <BODY>, <DIV> etc
-		uppercaseTagRe: regexp.MustCompile(`</?[A-Z][A-Z0-9]*`),
+		// Имя тега в начале сырого фрагмента: <HTML ...>, </BODY>
+		uppercaseTagRe: regexp.MustCompile(`^</?([A-Za-z][A-Za-z0-9]*)`),
 
 		// Атрибуты в uppercase: CLASS="test", ID="main"
-		uppercaseAttrRe: regexp.MustCompile(`\s+[A-Z][A-Z0-9_-]*=`),
+		uppercaseAttrRe: regexp.MustCompile(`\s+([A-Za-z][A-Za-z0-9_-]*)=`),
 
 		// Атрибуты без кавычек: src=pic.jpg вместо src="pic.jpg"
 		unquotedAttrRe: regexp.MustCompile(`\s+(\w+)=([^"'][^\s>]+)`),
-
-		// Незакрытые void элементы: <br> вместо <br />
-		// Проверяем что тег заканчивается на > без / перед ним
-		unclosedVoidRe: regexp.MustCompile(`<(br|img|input|meta|link|hr|area|base|col|embed|param|source|track|wbr)(\s[^/>]*|)>`),
 	}
 }
 
@@ -51,79 +52,147 @@ const (
 	IssueInvalidNesting
 )
 
-// Validate проверяет HTML и возвращает список проблем
+// Validate проверяет HTML и возвращает список проблем в порядке их появления в документе
 func (pv *PreValidator) Validate(input string) []ValidationIssue {
 	var issues []ValidationIssue
 
-	// Проверка uppercase тегов
-	if matches := pv.uppercaseTagRe.FindAllString(input, -1); len(matches) > 0 {
-		seen := make(map[string]bool)
-		for _, match := range matches {
-			tagName := strings.TrimPrefix(strings.TrimPrefix(match, "</"), "<")
-			if !seen[tagName] {
-				seen[tagName] = true
+	z := html.NewTokenizer(strings.NewReader(input))
+	line, col := 1, 1
+	var openTags []string
+
+	for {
+		tt := z.Next()
+		// Копируем: z.Raw() - это вид в общий буфер токенайзера, а TagName()/TagAttr()
+		// ниже лowercase-ят этот буфер на месте - без копии checkTag/checkUppercaseTag
+		// увидели бы уже нормализованные (всегда lowercase) байты и никогда не находили
+		// бы uppercase-теги/атрибуты
+		raw := append([]byte(nil), z.Raw()...)
+		startLine, startCol := line, col
+
+		switch tt {
+		case html.ErrorToken:
+			return issues
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			nameBytes, _ := z.TagName()
+			tagName := strings.ToLower(string(nameBytes))
+			issues = append(issues, pv.checkTag(raw, tagName, startLine, startCol)...)
+
+			if tt == html.StartTagToken && !isVoidElement(tagName) {
+				openTags = append(openTags, tagName)
+			}
+
+		case html.EndTagToken:
+			nameBytes, _ := z.TagName()
+			tagName := strings.ToLower(string(nameBytes))
+
+			if issue := pv.checkUppercaseTag(raw, startLine, startCol); issue != nil {
+				issues = append(issues, *issue)
+			}
+
+			idx := -1
+			for i := len(openTags) - 1; i >= 0; i-- {
+				if openTags[i] == tagName {
+					idx = i
+					break
+				}
+			}
+
+			if idx < 0 {
 				issues = append(issues, ValidationIssue{
-					Type:     IssueUppercaseTag,
-					Message:  "Tag must be lowercase",
-					Original: tagName,
-					Fixed:    strings.ToLower(tagName),
+					Type:     IssueInvalidNesting,
+					Line:     startLine,
+					Column:   startCol,
+					Message:  "Closing tag has no matching open tag",
+					Original: string(raw),
 				})
+			} else {
+				if idx != len(openTags)-1 {
+					issues = append(issues, ValidationIssue{
+						Type:     IssueInvalidNesting,
+						Line:     startLine,
+						Column:   startCol,
+						Message:  "Overlapping tags: other elements are still open at this closing tag",
+						Original: string(raw),
+					})
+				}
+				openTags = openTags[:idx]
 			}
 		}
-	}
 
-	// Проверка uppercase атрибутов
-	if matches := pv.uppercaseAttrRe.FindAllString(input, -1); len(matches) > 0 {
-		seen := make(map[string]bool)
-		for _, match := range matches {
-			attrName := strings.TrimSuffix(strings.TrimSpace(match), "=")
-			if !seen[attrName] {
-				seen[attrName] = true
-				issues = append(issues, ValidationIssue{
-					Type:     IssueUppercaseAttr,
-					Message:  "Attribute must be lowercase",
-					Original: attrName,
-					Fixed:    strings.ToLower(attrName),
-				})
+		for _, b := range raw {
+			if b == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
 			}
 		}
 	}
+}
 
-	// Проверка атрибутов без кавычек
-	if matches := pv.unquotedAttrRe.FindAllStringSubmatch(input, -1); len(matches) > 0 {
-		seen := make(map[string]bool)
-		for _, match := range matches {
-			if len(match) >= 3 {
-				key := match[0]
-				if !seen[key] {
-					seen[key] = true
-					issues = append(issues, ValidationIssue{
-						Type:     IssueUnquotedAttr,
-						Message:  "Attribute value must be quoted",
-						Original: match[0],
-						Fixed:    match[1] + `="` + match[2] + `"`,
-					})
-				}
-			}
+// checkUppercaseTag проверяет имя тега (открывающего или закрывающего) на uppercase буквы
+func (pv *PreValidator) checkUppercaseTag(raw []byte, line, col int) *ValidationIssue {
+	m := pv.uppercaseTagRe.FindStringSubmatch(string(raw))
+	if m == nil || m[1] == strings.ToLower(m[1]) {
+		return nil
+	}
+	return &ValidationIssue{
+		Type:     IssueUppercaseTag,
+		Line:     line,
+		Column:   col,
+		Message:  "Tag must be lowercase",
+		Original: m[1],
+		Fixed:    strings.ToLower(m[1]),
+	}
+}
+
+// checkTag проверяет сырые байты одного тега (без нормализации регистра, которую
+// делает html.Tokenizer в TagName/TagAttr) на uppercase имя/атрибуты, незакавыченные
+// значения и незакрытые void элементы
+func (pv *PreValidator) checkTag(raw []byte, tagName string, line, col int) []ValidationIssue {
+	var issues []ValidationIssue
+	text := string(raw)
+
+	if issue := pv.checkUppercaseTag(raw, line, col); issue != nil {
+		issues = append(issues, *issue)
+	}
+
+	for _, m := range pv.uppercaseAttrRe.FindAllStringSubmatch(text, -1) {
+		if m[1] != strings.ToLower(m[1]) {
+			issues = append(issues, ValidationIssue{
+				Type:     IssueUppercaseAttr,
+				Line:     line,
+				Column:   col,
+				Message:  "Attribute must be lowercase",
+				Original: m[1],
+				Fixed:    strings.ToLower(m[1]),
+			})
 		}
 	}
 
-	// Проверка незакрытых void элементов
-	if matches := pv.unclosedVoidRe.FindAllStringSubmatch(input, -1); len(matches) > 0 {
-		seen := make(map[string]bool)
-		for _, match := range matches {
-			if len(match) >= 1 {
-				tagName := match[1]
-				if !seen[tagName] {
-					seen[tagName] = true
-					issues = append(issues, ValidationIssue{
-						Type:     IssueUnclosedVoid,
-						Message:  "Void element must be self-closing",
-						Original: "<" + tagName + ">",
-						Fixed:    "<" + tagName + " />",
-					})
-				}
-			}
+	for _, m := range pv.unquotedAttrRe.FindAllStringSubmatch(text, -1) {
+		issues = append(issues, ValidationIssue{
+			Type:     IssueUnquotedAttr,
+			Line:     line,
+			Column:   col,
+			Message:  "Attribute value must be quoted",
+			Original: m[0],
+			Fixed:    m[1] + `="` + m[2] + `"`,
+		})
+	}
+
+	if isVoidElement(tagName) {
+		trimmed := strings.TrimSuffix(strings.TrimRight(text, " \t\n\r"), ">")
+		if !strings.HasSuffix(trimmed, "/") {
+			issues = append(issues, ValidationIssue{
+				Type:     IssueUnclosedVoid,
+				Line:     line,
+				Column:   col,
+				Message:  "Void element must be self-closing",
+				Original: "<" + tagName + ">",
+				Fixed:    "<" + tagName + " />",
+			})
 		}
 	}
 
@@ -0,0 +1,105 @@
+// pkg/converter/prevalidator_test.go
+package converter
+
+import "testing"
+
+func TestPreValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		issues []ValidationIssue
+	}{
+		{
+			name:  "uppercase tag",
+			input: `<DIV>test</DIV>`,
+			issues: []ValidationIssue{
+				{Type: IssueUppercaseTag, Line: 1, Column: 1, Original: "DIV", Fixed: "div"},
+				{Type: IssueUppercaseTag, Line: 1, Column: 10, Original: "DIV", Fixed: "div"},
+			},
+		},
+		{
+			name:  "uppercase attribute",
+			input: `<div CLASS="test">x</div>`,
+			issues: []ValidationIssue{
+				{Type: IssueUppercaseAttr, Line: 1, Column: 1, Original: "CLASS", Fixed: "class"},
+			},
+		},
+		{
+			name:  "unquoted attribute",
+			input: `<img src=pic.jpg>`,
+			issues: []ValidationIssue{
+				{Type: IssueUnquotedAttr, Line: 1, Column: 1, Original: " src=pic.jpg", Fixed: `src="pic.jpg"`},
+				{Type: IssueUnclosedVoid, Line: 1, Column: 1, Original: "<img>", Fixed: "<img />"},
+			},
+		},
+		{
+			name:   "self-closed void element has no issue",
+			input:  `<br />`,
+			issues: nil,
+		},
+		{
+			name:  "unclosed void element",
+			input: `<br>`,
+			issues: []ValidationIssue{
+				{Type: IssueUnclosedVoid, Line: 1, Column: 1, Original: "<br>", Fixed: "<br />"},
+			},
+		},
+		{
+			name:  "overlapping tags",
+			input: `<b><i>x</b></i>`,
+			issues: []ValidationIssue{
+				{Type: IssueInvalidNesting, Line: 1, Column: 8, Message: "Overlapping tags: other elements are still open at this closing tag"},
+				{Type: IssueInvalidNesting, Line: 1, Column: 12, Message: "Closing tag has no matching open tag"},
+			},
+		},
+		{
+			name:  "line and column track newlines",
+			input: "<div>\n<SPAN>x</SPAN>",
+			issues: []ValidationIssue{
+				{Type: IssueUppercaseTag, Line: 2, Column: 1, Original: "SPAN", Fixed: "span"},
+				{Type: IssueUppercaseTag, Line: 2, Column: 8, Original: "SPAN", Fixed: "span"},
+			},
+		},
+	}
+
+	pv := NewPreValidator()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pv.Validate(tt.input)
+			if len(got) != len(tt.issues) {
+				t.Fatalf("Validate() returned %d issues, want %d: %+v", len(got), len(tt.issues), got)
+			}
+			for i, want := range tt.issues {
+				if got[i].Type != want.Type || got[i].Line != want.Line || got[i].Column != want.Column {
+					t.Errorf("issue %d = %+v, want %+v", i, got[i], want)
+				}
+				if want.Original != "" && got[i].Original != want.Original {
+					t.Errorf("issue %d Original = %q, want %q", i, got[i].Original, want.Original)
+				}
+				if want.Fixed != "" && got[i].Fixed != want.Fixed {
+					t.Errorf("issue %d Fixed = %q, want %q", i, got[i].Fixed, want.Fixed)
+				}
+				if want.Message != "" && got[i].Message != want.Message {
+					t.Errorf("issue %d Message = %q, want %q", i, got[i].Message, want.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestCountIssuesByType(t *testing.T) {
+	pv := NewPreValidator()
+	issues := pv.Validate(`<DIV CLASS="x"><br></DIV>`)
+
+	counts := CountIssuesByType(issues)
+	if counts[IssueUppercaseTag] != 2 {
+		t.Errorf("IssueUppercaseTag count = %d, want 2", counts[IssueUppercaseTag])
+	}
+	if counts[IssueUppercaseAttr] != 1 {
+		t.Errorf("IssueUppercaseAttr count = %d, want 1", counts[IssueUppercaseAttr])
+	}
+	if counts[IssueUnclosedVoid] != 1 {
+		t.Errorf("IssueUnclosedVoid count = %d, want 1", counts[IssueUnclosedVoid])
+	}
+}
@@ -0,0 +1,173 @@
+// pkg/converter/prometheus/prometheus.go
+package prometheus
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/ruslano69/shp/pkg/converter"
+)
+
+// defaultDurationBuckets покрывает диапазон от десятков микросекунд (быстрая
+// конвертация небольшого фрагмента) до нескольких секунд (большой документ под
+// нагрузкой), в отличие от prometheus.DefBuckets, рассчитанных на HTTP-задержки.
+var defaultDurationBuckets = []float64{
+	0.00001, 0.00005, 0.0001, 0.0005,
+	0.001, 0.005, 0.01, 0.05,
+	0.1, 0.5, 1, 5, 10,
+}
+
+// defaultSizeBuckets бакеты для гистограмм размера документа в байтах: от
+// небольших фрагментов до документов в несколько мегабайт
+var defaultSizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+
+// options накапливает Option'ы, переданные в NewPrometheusMetrics
+type options struct {
+	durationBuckets []float64
+	sizeBuckets     []float64
+}
+
+// Option настраивает NewPrometheusMetrics
+type Option func(*options)
+
+// WithDurationBuckets переопределяет бакеты гистограммы длительности конвертации
+func WithDurationBuckets(buckets []float64) Option {
+	return func(o *options) { o.durationBuckets = buckets }
+}
+
+// WithSizeBuckets переопределяет бакеты гистограмм входного/выходного размера документа
+func WithSizeBuckets(buckets []float64) Option {
+	return func(o *options) { o.sizeBuckets = buckets }
+}
+
+// PrometheusMetrics реализует converter.Metrics поверх стандартного клиента
+// Prometheus. Счетчики и гистограммы регистрируются на переданном
+// prometheus.Registerer, поэтому их можно встроить в уже существующий реестр
+// приложения, а не только в prometheus.DefaultRegisterer.
+//
+// Prometheus-счетчики монотонно растут и не читаются обратно напрямую, поэтому
+// GetStats()/Reset() делегируются на shadow - обычный converter.NewMetrics(),
+// которому отправляется та же самая статистика, что и в Prometheus-коллекторы.
+type PrometheusMetrics struct {
+	conversions  *prometheus.CounterVec
+	duration     prometheus.Histogram
+	inputSize    prometheus.Histogram
+	outputSize   prometheus.Histogram
+	errors       *prometheus.CounterVec
+	changes      *prometheus.CounterVec
+	rulesApplied *prometheus.CounterVec
+
+	shadow converter.Metrics
+}
+
+// NewPrometheusMetrics создает и регистрирует метрики SHP на reg и возвращает готовый
+// converter.Metrics. Паникует, если какая-то из метрик уже зарегистрирована на reg
+// (как и обычные конструкторы client_golang), поэтому вызывать его следует один раз
+// на reg.
+func NewPrometheusMetrics(reg prometheus.Registerer, opts ...Option) converter.Metrics {
+	o := options{
+		durationBuckets: defaultDurationBuckets,
+		sizeBuckets:     defaultSizeBuckets,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	m := &PrometheusMetrics{
+		conversions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shp_conversions_total",
+			Help: "Total number of HTML to XHTML conversions, by outcome.",
+		}, []string{"outcome"}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "shp_conversion_duration_seconds",
+			Help:    "Duration of HTML to XHTML conversions.",
+			Buckets: o.durationBuckets,
+		}),
+		inputSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "shp_input_bytes",
+			Help:    "Size of the input HTML document, in bytes.",
+			Buckets: o.sizeBuckets,
+		}),
+		outputSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "shp_output_bytes",
+			Help:    "Size of the converted XHTML document, in bytes.",
+			Buckets: o.sizeBuckets,
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shp_errors_total",
+			Help: "Total number of conversion errors, by error code.",
+		}, []string{"code"}),
+		changes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shp_changes_total",
+			Help: "Total number of XHTML fixes applied during conversion, by change type.",
+		}, []string{"type"}),
+		rulesApplied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shp_rule_applied_total",
+			Help: "Total number of times a converter.Rule matched and was applied, by rule name.",
+		}, []string{"rule"}),
+		shadow: converter.NewMetrics(),
+	}
+
+	reg.MustRegister(
+		m.conversions,
+		m.duration,
+		m.inputSize,
+		m.outputSize,
+		m.errors,
+		m.changes,
+		m.rulesApplied,
+	)
+
+	return m
+}
+
+func (m *PrometheusMetrics) RecordConversion(duration time.Duration, inputSize, outputSize int64) {
+	m.conversions.WithLabelValues("success").Inc()
+	m.duration.Observe(duration.Seconds())
+	m.inputSize.Observe(float64(inputSize))
+	m.outputSize.Observe(float64(outputSize))
+	m.shadow.RecordConversion(duration, inputSize, outputSize)
+}
+
+func (m *PrometheusMetrics) RecordError(errorType converter.ErrorCode) {
+	m.conversions.WithLabelValues("error").Inc()
+	m.errors.WithLabelValues(errorType.String()).Inc()
+	m.shadow.RecordError(errorType)
+}
+
+func (m *PrometheusMetrics) RecordChange(changeType converter.ChangeType) {
+	m.changes.WithLabelValues(changeType.String()).Inc()
+	m.shadow.RecordChange(changeType)
+}
+
+func (m *PrometheusMetrics) RecordRuleApplied(rule string) {
+	m.rulesApplied.WithLabelValues(rule).Inc()
+	m.shadow.RecordRuleApplied(rule)
+}
+
+// GetStats читает статистику обратно из shadow - объект в памяти, которому
+// отправляется та же самая статистика, что и в Prometheus-коллекторы. Сами
+// коллекторы Prometheus предназначены для выгрузки через /metrics (см. Handler),
+// а не для чтения значений обратно в процессе.
+func (m *PrometheusMetrics) GetStats() converter.ConversionStats {
+	return m.shadow.GetStats()
+}
+
+// Reset сбрасывает только shadow-снэпшот, используемый GetStats(). Сами
+// Prometheus-счетчики не сбрасываются: в модели Prometheus счетчики монотонно
+// растут, и их "уменьшение в рантайме" выглядело бы для алертов как утечка или
+// рестарт процесса.
+func (m *PrometheusMetrics) Reset() {
+	m.shadow.Reset()
+}
+
+// Handler возвращает http.Handler, которым middleware можно отдавать /metrics для
+// скрейпинга Prometheus. Принимает любой prometheus.Gatherer: как *prometheus.Registry,
+// переданный в NewPrometheusMetrics, так и prometheus.DefaultGatherer, если метрики
+// были зарегистрированы через prometheus.DefaultRegisterer
+func Handler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,196 @@
+// pkg/converter/rule.go
+package converter
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Rule одно автоисправление, применяемое к узлу DOM во время AutoFix. ID - стабильный
+// идентификатор правила (shp_rule_applied_total{rule}, Change.RuleID, Options.EnableRules/
+// DisableRules) - не должен меняться между версиями. Description - короткое
+// человекочитаемое описание для справки (например, `-rule-enable`/`-rule-disable` в
+// cmd/shp-convert). Match сообщает, нужно ли применять исправление к данному узлу;
+// Apply выполняет его и возвращает Change для отчета, либо ошибку, если применить
+// правило не удалось (в этом случае Change не засчитывается, а ошибка попадает в
+// Result.Warnings). Пользователи могут регистрировать свои Rule через NewWithRules,
+// RegisterRule или Options.Rules, не форкая converter (например, убрать javascript:
+// URL, переписать устаревший <center> в <div style=...>, добавить xmlns на корневой
+// элемент).
+//
+// Apply может мутировать n на месте (как встроенные правила ниже), удалить n из
+// дерева через n.Parent.RemoveChild(n) (см. RuleStripTag), или заменить n набором
+// узлов через n.Parent.InsertBefore перед удалением n - обход в fixNode устойчив
+// к удалению текущего узла.
+type Rule interface {
+	ID() string
+	Description() string
+	Match(n *html.Node) bool
+	Apply(n *html.Node) (Change, error)
+}
+
+// Идентификаторы встроенных правил - используются в Change.RuleID, метриках и
+// Options.EnableRules/DisableRules. quote-attrs (кавычки вокруг значений атрибутов)
+// и close-voids (самозакрытие void-элементов) в этот список не входят: это не
+// Rule-проходы по дереву, а безусловное поведение renderXHTML/StreamConverter.writeTag,
+// без которого вывод перестает быть валидным XHTML - их нельзя отключить через реестр.
+//
+// lowercase-tags/lowercase-attrs не соответствуют Rule, зарегистрированному в
+// DefaultRules(): html.Parse() приводит имена тегов и ключи атрибутов к lowercase
+// во время построения DOM, так что к моменту, когда правило из DefaultRules() увидело
+// бы узел, исходный регистр уже потерян безвозвратно - Match() никогда не сработал бы.
+// В DOM-режиме регистр исправляет сам html.Parse; Convert (но не ConvertWithContext)
+// дополнительно записывает в Result.Changes проблемы, найденные PreValidator на сырых
+// байтах до парсинга. Идентификаторы остаются здесь,
+// потому что StreamConverter.writeTag (потоковый режим, работающий по сырым токенам,
+// а не по DOM) использует их по-настоящему - см. stream.go.
+const (
+	ruleIDLowercaseTags  = "lowercase-tags"
+	ruleIDLowercaseAttrs = "lowercase-attrs"
+)
+
+// DefaultRules встроенные правила исправления. lowercase-tags/lowercase-attrs сюда не
+// входят (см. комментарий к ruleIDLowercaseTags выше) - приведение регистра в DOM-режиме
+// выполняется до Rule-прохода, отдельным шагом в Convert.
+func DefaultRules() []Rule {
+	return nil
+}
+
+// RuleStripTag удаляет из дерева весь элемент tag вместе с его потомками -
+// например RuleStripTag("script"), чтобы не переносить скрипты в XHTML-вывод
+func RuleStripTag(tag string) Rule {
+	return stripTagRule{tag: strings.ToLower(tag)}
+}
+
+type stripTagRule struct {
+	tag string
+}
+
+func (r stripTagRule) ID() string          { return "strip-tag:" + r.tag }
+func (r stripTagRule) Description() string { return "Removes <" + r.tag + "> elements and their children" }
+
+func (r stripTagRule) Match(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.Data == r.tag && n.Parent != nil
+}
+
+func (r stripTagRule) Apply(n *html.Node) (Change, error) {
+	n.Parent.RemoveChild(n)
+	return Change{
+		Type:     ChangeStrippedElement,
+		Message:  "Removed element",
+		Original: "<" + r.tag + ">",
+	}, nil
+}
+
+// RuleDropAttrsMatching удаляет атрибуты, чье имя матчится pattern - например
+// RuleDropAttrsMatching(regexp.MustCompile(`^on`)), чтобы вырезать инлайновые
+// обработчики событий (onclick, onerror, ...)
+func RuleDropAttrsMatching(pattern *regexp.Regexp) Rule {
+	return dropAttrsMatchingRule{pattern: pattern}
+}
+
+type dropAttrsMatchingRule struct {
+	pattern *regexp.Regexp
+}
+
+func (r dropAttrsMatchingRule) ID() string { return "drop-attrs-matching:" + r.pattern.String() }
+func (r dropAttrsMatchingRule) Description() string {
+	return "Drops attributes matching " + r.pattern.String()
+}
+
+func (r dropAttrsMatchingRule) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, attr := range n.Attr {
+		if r.pattern.MatchString(attr.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r dropAttrsMatchingRule) Apply(n *html.Node) (Change, error) {
+	var kept []html.Attribute
+	var dropped []string
+	for _, attr := range n.Attr {
+		if r.pattern.MatchString(attr.Key) {
+			dropped = append(dropped, attr.Key)
+			continue
+		}
+		kept = append(kept, attr)
+	}
+	n.Attr = kept
+	return Change{
+		Type:     ChangeDroppedAttr,
+		Message:  "Dropped attributes matching " + r.pattern.String(),
+		Original: strings.Join(dropped, ", "),
+	}, nil
+}
+
+// RuleRequireAttr добавляет attr="" на tag, если он отсутствует - например
+// RuleRequireAttr("img", "alt") для базовой доступности разметки
+func RuleRequireAttr(tag, attr string) Rule {
+	return requireAttrRule{tag: strings.ToLower(tag), attr: attr}
+}
+
+type requireAttrRule struct {
+	tag  string
+	attr string
+}
+
+func (r requireAttrRule) ID() string { return "require-attr:" + r.tag + "/" + r.attr }
+func (r requireAttrRule) Description() string {
+	return "Adds missing " + r.attr + " attribute to <" + r.tag + ">"
+}
+
+func (r requireAttrRule) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.Data != r.tag {
+		return false
+	}
+	for _, a := range n.Attr {
+		if a.Key == r.attr {
+			return false
+		}
+	}
+	return true
+}
+
+func (r requireAttrRule) Apply(n *html.Node) (Change, error) {
+	n.Attr = append(n.Attr, html.Attribute{Key: r.attr, Val: ""})
+	return Change{
+		Type:    ChangeMissingRequiredAttr,
+		Message: "Added required attribute " + r.attr + " to <" + r.tag + ">",
+		Fixed:   r.attr + `=""`,
+	}, nil
+}
+
+// RuleRewriteTag переименовывает элемент from в to на месте - например
+// RuleRewriteTag("font", "span") для замены устаревших тегов презентации
+func RuleRewriteTag(from, to string) Rule {
+	return rewriteTagRule{from: strings.ToLower(from), to: strings.ToLower(to)}
+}
+
+type rewriteTagRule struct {
+	from string
+	to   string
+}
+
+func (r rewriteTagRule) ID() string          { return "rewrite-tag:" + r.from + "->" + r.to }
+func (r rewriteTagRule) Description() string { return "Renames <" + r.from + "> elements to <" + r.to + ">" }
+
+func (r rewriteTagRule) Match(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.Data == r.from
+}
+
+func (r rewriteTagRule) Apply(n *html.Node) (Change, error) {
+	n.Data = r.to
+	return Change{
+		Type:     ChangeRewrittenTag,
+		Message:  "Rewrote tag",
+		Original: "<" + r.from + ">",
+		Fixed:    "<" + r.to + ">",
+	}, nil
+}
@@ -0,0 +1,236 @@
+// pkg/converter/rule_test.go
+package converter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// stripDataAttrRule пример пользовательского правила, подключаемого через
+// NewWithRules: удаляет все data-* атрибуты
+type stripDataAttrRule struct{}
+
+func (stripDataAttrRule) ID() string          { return "strip-data-attr" }
+func (stripDataAttrRule) Description() string { return "Removes all data-* attributes" }
+
+func (stripDataAttrRule) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, attr := range n.Attr {
+		if strings.HasPrefix(attr.Key, "data-") {
+			return true
+		}
+	}
+	return false
+}
+
+func (stripDataAttrRule) Apply(n *html.Node) (Change, error) {
+	var kept []html.Attribute
+	var removed string
+	for _, attr := range n.Attr {
+		if strings.HasPrefix(attr.Key, "data-") {
+			removed = attr.Key
+			continue
+		}
+		kept = append(kept, attr)
+	}
+	n.Attr = kept
+	return Change{
+		Type:     ChangeMissingNamespace,
+		Message:  "Removed data-* attribute",
+		Original: removed,
+	}, nil
+}
+
+func TestNewWithRules_CustomRule(t *testing.T) {
+	metrics := NewMetrics()
+	conv := NewWithRules(metrics, append(DefaultRules(), stripDataAttrRule{}))
+
+	result, err := conv.ConvertWithContext(context.Background(), []byte(`<div data-test="x">hi</div>`), Options{AutoFix: true})
+	if err != nil {
+		t.Fatalf("ConvertWithContext() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div>hi</div></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("ConvertWithContext() output = %s, want %s", result.Output, want)
+	}
+
+	found := false
+	for _, c := range result.Changes {
+		if c.Message == "Removed data-* attribute" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected custom rule's Change to be recorded")
+	}
+
+	stats := metrics.GetStats()
+	if stats.RulesApplied["strip-data-attr"] != 1 {
+		t.Errorf("RulesApplied[strip-data-attr] = %d, want 1", stats.RulesApplied["strip-data-attr"])
+	}
+}
+
+func TestNewWithRules_NilMetrics(t *testing.T) {
+	conv := NewWithRules(nil, DefaultRules())
+	result, err := conv.ConvertWithContext(context.Background(), []byte(`<div>hi</div>`), Options{AutoFix: true})
+	if err != nil {
+		t.Fatalf("ConvertWithContext() error = %v", err)
+	}
+	if !result.Success {
+		t.Error("expected Success = true")
+	}
+}
+
+func TestRuleStripTag_RemovesElementAndChildren(t *testing.T) {
+	conv := New()
+	result, err := conv.Convert([]byte(`<div><script>alert(1)</script><p>hi</p></div>`), Options{
+		AutoFix: true,
+		Rules:   []Rule{RuleStripTag("script")},
+	})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div><p>hi</p></div></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("Convert() output = %s, want %s", result.Output, want)
+	}
+
+	found := false
+	for _, c := range result.Changes {
+		if c.Type == ChangeStrippedElement {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected ChangeStrippedElement to be recorded")
+	}
+}
+
+func TestRuleDropAttrsMatching_RemovesMatchingAttrs(t *testing.T) {
+	conv := New()
+	result, err := conv.Convert([]byte(`<div onclick="bad()" id="x">hi</div>`), Options{
+		AutoFix: true,
+		Rules:   []Rule{RuleDropAttrsMatching(regexp.MustCompile(`^on`))},
+	})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div id="x">hi</div></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("Convert() output = %s, want %s", result.Output, want)
+	}
+}
+
+func TestRuleRequireAttr_AddsMissingAttr(t *testing.T) {
+	conv := New()
+	result, err := conv.Convert([]byte(`<img src="x.png">`), Options{
+		AutoFix: true,
+		Rules:   []Rule{RuleRequireAttr("img", "alt")},
+	})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<html><head></head><body><img src="x.png" alt="" /></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("Convert() output = %s, want %s", result.Output, want)
+	}
+}
+
+func TestRuleRewriteTag_RenamesElement(t *testing.T) {
+	conv := New()
+	result, err := conv.Convert([]byte(`<font color="red">hi</font>`), Options{
+		AutoFix: true,
+		Rules:   []Rule{RuleRewriteTag("font", "span")},
+	})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<html><head></head><body><span color="red">hi</span></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("Convert() output = %s, want %s", result.Output, want)
+	}
+}
+
+func TestRule_DisableRules_SkipsMatchingRule(t *testing.T) {
+	conv := New()
+	rule := RuleStripTag("script")
+	result, err := conv.Convert([]byte(`<div><script>alert(1)</script></div>`), Options{
+		AutoFix:      true,
+		Rules:        []Rule{rule},
+		DisableRules: []string{rule.ID()},
+	})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div><script>alert(1)</script></div></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("Convert() output = %s, want %s (rule should be disabled)", result.Output, want)
+	}
+}
+
+func TestRule_EnableRules_AllowsOnlyListedRule(t *testing.T) {
+	conv := New()
+	strip := RuleStripTag("script")
+	rewrite := RuleRewriteTag("font", "span")
+	result, err := conv.Convert([]byte(`<div><script>alert(1)</script><font>hi</font></div>`), Options{
+		AutoFix:     true,
+		Rules:       []Rule{strip, rewrite},
+		EnableRules: []string{strip.ID()},
+	})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div><font>hi</font></div></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("Convert() output = %s, want %s (only strip-tag should run)", result.Output, want)
+	}
+}
+
+func TestRule_ChangeRecordsRuleID(t *testing.T) {
+	conv := New()
+	result, err := conv.Convert([]byte(`<img src="x.png">`), Options{
+		AutoFix: true,
+		Rules:   []Rule{RuleRequireAttr("img", "alt")},
+	})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	found := false
+	for _, c := range result.Changes {
+		if c.RuleID == "require-attr:img/alt" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a Change with RuleID %q, got %+v", "require-attr:img/alt", result.Changes)
+	}
+}
+
+func TestDefaultConverter_RegisterRule(t *testing.T) {
+	conv := New()
+	conv.RegisterRule(RuleStripTag("script"))
+
+	result, err := conv.Convert([]byte(`<div><script>alert(1)</script><p>hi</p></div>`), Options{AutoFix: true})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<html><head></head><body><div><p>hi</p></div></body></html>`
+	if string(result.Output) != want {
+		t.Errorf("Convert() output = %s, want %s", result.Output, want)
+	}
+}
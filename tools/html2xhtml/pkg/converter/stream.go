@@ -0,0 +1,248 @@
+// pkg/converter/stream.go
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// StreamConverter конвертирует HTML → XHTML потоково, без буферизации всего DOM в память.
+// В отличие от DefaultConverter.Convert он читает входной io.Reader токен за токеном
+// и сразу пишет нормализованный XHTML в io.Writer, что убирает задержку до первого байта
+// и вдвое меньше расходует память на больших страницах.
+type StreamConverter struct {
+	metrics Metrics
+}
+
+// NewStreamConverter создает потоковый конвертер с опциональным сбором метрик
+func NewStreamConverter(metrics Metrics) *StreamConverter {
+	if metrics == nil {
+		metrics = &NoOpMetrics{}
+	}
+	return &StreamConverter{metrics: metrics}
+}
+
+// countingReader считает количество прочитанных байт
+type countingReader struct {
+	r     io.Reader
+	count int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.count += int64(n)
+	return n, err
+}
+
+// Convert читает HTML из r и пишет XHTML в w по мере разбора токенов. Незакрытые
+// теги на конце документа закрываются автоматически, как и в DOM-режиме AutoFix.
+func (sc *StreamConverter) Convert(ctx context.Context, r io.Reader, w io.Writer, opts Options) (*Result, error) {
+	startTime := time.Now()
+
+	select {
+	case <-ctx.Done():
+		return nil, NewError(ErrContextCanceled, "context canceled", ctx.Err())
+	default:
+	}
+
+	cr := &countingReader{r: r}
+	bw := bufio.NewWriter(w)
+	z := html.NewTokenizer(cr)
+
+	result := &Result{}
+	var openTags []string
+	var written int64
+	line := 1 // 1-based, отслеживается по z.Raw() - см. комментарий ниже
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, NewError(ErrContextCanceled, "context canceled during streaming", ctx.Err())
+		default:
+		}
+
+		tt := z.Next()
+		// z.Raw() - это исходные байты just-parsed токена в порядке документа,
+		// в отличие от cr.count эта позиция не зависит от того, как Tokenizer
+		// буферизует чтение из io.Reader - поэтому именно по ней, а не по
+		// countingReader, можно точно считать номер строки. Копируем: это вид
+		// в общий буфер токенайзера, а TagName()/TagAttr() в writeTag ниже
+		// приводят этот буфер к lowercase на месте - без копии writeTag видел
+		// бы уже нормализованные байты и не смог бы отличить исходный регистр.
+		raw := append([]byte(nil), z.Raw()...)
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				sc.metrics.RecordError(ErrParseFailed)
+				return nil, NewError(ErrParseFailed, "failed to tokenize HTML", err)
+			}
+
+			// Закрываем оставшиеся открытые теги
+			for i := len(openTags) - 1; i >= 0; i-- {
+				n, _ := bw.WriteString("</" + openTags[i] + ">")
+				written += int64(n)
+			}
+			if err := bw.Flush(); err != nil {
+				sc.metrics.RecordError(ErrConversionFailed)
+				return nil, NewError(ErrConversionFailed, "failed to flush output", err)
+			}
+
+			result.OriginalSize = cr.count
+			result.FinalSize = written
+			result.Success = true
+
+			sc.metrics.RecordConversion(time.Since(startTime), result.OriginalSize, result.FinalSize)
+
+			return result, nil
+
+		case html.TextToken:
+			n, _ := bw.WriteString(html.EscapeString(string(z.Text())))
+			written += int64(n)
+			line += bytes.Count(raw, newline)
+
+		case html.CommentToken:
+			n, _ := bw.WriteString("<!--" + string(z.Text()) + "-->")
+			written += int64(n)
+			line += bytes.Count(raw, newline)
+
+		case html.DoctypeToken:
+			n, _ := bw.WriteString("<!DOCTYPE " + string(z.Text()) + ">")
+			written += int64(n)
+			line += bytes.Count(raw, newline)
+
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			n, name := sc.writeTag(bw, z, raw, tt, result, opts, line)
+			written += n
+			line += bytes.Count(raw, newline)
+
+			switch tt {
+			case html.StartTagToken:
+				if !isVoidElement(name) {
+					openTags = append(openTags, name)
+				}
+			case html.EndTagToken:
+				for i := len(openTags) - 1; i >= 0; i-- {
+					if openTags[i] == name {
+						openTags = openTags[:i]
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+var newline = []byte("\n")
+
+// tagNameRawRe/attrRawRe извлекают исходный регистр имени тега/атрибута из сырых
+// байт токена (см. writeTag) - z.TagName()/z.TagAttr() всегда возвращают lowercase,
+// это особенность Tokenizer, а не сигнал о том, что тег уже был написан lowercase.
+//
+// attrRawRe матчит имя атрибута независимо от того, есть ли у него значение
+// (`CHECKED` наравне с `VALUE="x"`) - иначе матчи и вызовы z.TagAttr() идут по
+// разному числу элементов на тегах, смешивающих valueless- и valued-атрибуты, и
+// attrIdx в writeTag съезжает, путая имена/значения между соседними атрибутами.
+var (
+	tagNameRawRe = regexp.MustCompile(`^</?([A-Za-z][A-Za-z0-9:-]*)`)
+	attrRawRe    = regexp.MustCompile(`\s+([A-Za-z][A-Za-z0-9:_-]*)(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s>]*))?`)
+)
+
+// writeTag нормализует и пишет один тег (start/self-closing/end), возвращая число
+// записанных байт и нормализованное (lowercase) имя тега. Попутно регистрирует
+// Change для тегов и атрибутов в верхнем регистре - через opts.OnChange, если он
+// задан, иначе накапливая в result.Changes. line - номер строки начала тега
+// (см. Convert), записывается в Change.Line. raw - исходные байты тега (см. Convert),
+// используется только для восстановления исходного регистра имени тега/атрибутов:
+// z.TagName()/z.TagAttr() сами по себе уже lowercase-нормализованы.
+func (sc *StreamConverter) writeTag(bw *bufio.Writer, z *html.Tokenizer, raw []byte, tt html.TokenType, result *Result, opts Options, line int) (int64, string) {
+	nameBytes, hasAttr := z.TagName()
+	lowerName := string(nameBytes)
+	rawName := lowerName
+	if m := tagNameRawRe.FindSubmatch(raw); m != nil {
+		rawName = string(m[1])
+	}
+	name := rawName
+	if ruleAllowed(ruleIDLowercaseTags, opts) {
+		name = lowerName
+	}
+	if name != rawName {
+		sc.recordChange(result, opts, Change{
+			Type:     ChangeUppercaseTag,
+			Message:  "Converted tag to lowercase",
+			Original: rawName,
+			Fixed:    name,
+			Line:     line,
+			RuleID:   ruleIDLowercaseTags,
+		})
+	}
+
+	if tt == html.EndTagToken {
+		n, _ := bw.WriteString("</" + name + ">")
+		return int64(n), name
+	}
+
+	var written int64
+	n, _ := bw.WriteString("<" + name)
+	written += int64(n)
+
+	attrMatches := attrRawRe.FindAllSubmatch(raw, -1)
+	attrIdx := 0
+	for hasAttr {
+		var keyBytes, valBytes []byte
+		keyBytes, valBytes, hasAttr = z.TagAttr()
+		lowerKey := string(keyBytes)
+		rawKey := lowerKey
+		if attrIdx < len(attrMatches) {
+			rawKey = string(attrMatches[attrIdx][1])
+		}
+		attrIdx++
+		key := rawKey
+		if ruleAllowed(ruleIDLowercaseAttrs, opts) {
+			key = lowerKey
+		}
+		if key != rawKey {
+			sc.recordChange(result, opts, Change{
+				Type:     ChangeUnquotedAttr,
+				Message:  "Converted attribute to lowercase",
+				Original: rawKey,
+				Fixed:    key,
+				Line:     line,
+				RuleID:   ruleIDLowercaseAttrs,
+			})
+		}
+		n, _ := bw.WriteString(" " + key + `="` + html.EscapeString(string(valBytes)) + `"`)
+		written += int64(n)
+	}
+
+	if isVoidElement(name) {
+		n, _ := bw.WriteString(" />")
+		return written + int64(n), name
+	}
+
+	if tt == html.SelfClosingTagToken {
+		// Не-void тег помечен самозакрывающимся — закрываем его честно
+		n, _ := bw.WriteString("></" + name + ">")
+		return written + int64(n), name
+	}
+
+	n, _ = bw.WriteString(">")
+	return written + int64(n), name
+}
+
+// recordChange репортит Change и засчитывает метрику сразу в момент обнаружения:
+// через opts.OnChange, если он задан (низкоаллокационный путь для больших
+// документов), иначе добавляя в result.Changes как в DOM-режиме.
+func (sc *StreamConverter) recordChange(result *Result, opts Options, change Change) {
+	sc.metrics.RecordChange(change.Type)
+	if opts.OnChange != nil {
+		opts.OnChange(change)
+		return
+	}
+	result.Changes = append(result.Changes, change)
+}
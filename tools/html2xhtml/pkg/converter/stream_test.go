@@ -0,0 +1,181 @@
+// pkg/converter/stream_test.go
+package converter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestStreamConverter_Convert(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "unclosed br tag",
+			input:    `<html><body><br></body></html>`,
+			expected: `<html><body><br /></body></html>`,
+		},
+		{
+			name:     "uppercase tags",
+			input:    `<HTML><BODY><DIV>test</DIV></BODY></HTML>`,
+			expected: `<html><body><div>test</div></body></html>`,
+		},
+		{
+			name:     "unquoted attributes",
+			input:    `<img src=pic.jpg width=100>`,
+			expected: `<img src="pic.jpg" width="100" />`,
+		},
+		{
+			name:     "special characters in text",
+			input:    `<p>A & B < C > D</p>`,
+			expected: `<p>A &amp; B &lt; C &gt; D</p>`,
+		},
+		{
+			name:     "unclosed trailing tag",
+			input:    `<div><p>text`,
+			expected: `<div><p>text</p></div>`,
+		},
+		{
+			name:     "boolean attribute mixed with valued attribute",
+			input:    `<input CHECKED VALUE="x">`,
+			expected: `<input checked="" value="x" />`,
+		},
+	}
+
+	sc := NewStreamConverter(nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			result, err := sc.Convert(context.Background(), strings.NewReader(tt.input), &out, Options{AutoFix: true})
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			got := strings.TrimSpace(out.String())
+			want := strings.TrimSpace(tt.expected)
+			if got != want {
+				t.Errorf("Convert() output mismatch\ngot:  %s\nwant: %s", got, want)
+			}
+
+			if !result.Success {
+				t.Error("expected Success = true")
+			}
+			if result.OriginalSize != int64(len(tt.input)) {
+				t.Errorf("OriginalSize = %d, want %d", result.OriginalSize, len(tt.input))
+			}
+		})
+	}
+}
+
+func TestStreamConverter_ContextCanceled(t *testing.T) {
+	sc := NewStreamConverter(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	_, err := sc.Convert(ctx, strings.NewReader(`<html></html>`), &out, Options{AutoFix: true})
+	if err == nil {
+		t.Error("expected context canceled error")
+	}
+}
+
+func TestStreamConverter_OnChangeCallback(t *testing.T) {
+	sc := NewStreamConverter(nil)
+
+	var changes []Change
+	opts := Options{
+		AutoFix: true,
+		OnChange: func(c Change) {
+			changes = append(changes, c)
+		},
+	}
+
+	var out bytes.Buffer
+	result, err := sc.Convert(context.Background(), strings.NewReader(`<DIV>hi</DIV>`), &out, opts)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	if len(result.Changes) != 0 {
+		t.Errorf("result.Changes = %v, want empty when OnChange is set", result.Changes)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("OnChange called %d times, want 2 (open + close tag)", len(changes))
+	}
+	for _, c := range changes {
+		if c.Type != ChangeUppercaseTag {
+			t.Errorf("change type = %v, want ChangeUppercaseTag", c.Type)
+		}
+	}
+}
+
+func TestStreamConverter_RecordsLineNumber(t *testing.T) {
+	sc := NewStreamConverter(nil)
+
+	var changes []Change
+	opts := Options{
+		AutoFix: true,
+		OnChange: func(c Change) {
+			changes = append(changes, c)
+		},
+	}
+
+	var out bytes.Buffer
+	input := "<html>\n<BODY>hi</BODY>\n</html>"
+	_, err := sc.Convert(context.Background(), strings.NewReader(input), &out, opts)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("OnChange called %d times, want 2", len(changes))
+	}
+	for _, c := range changes {
+		if c.Line != 2 {
+			t.Errorf("change.Line = %d, want 2 (the BODY tag is on line 2)", c.Line)
+		}
+	}
+}
+
+func TestStreamConverter_BooleanAttrWithLowercaseAttrsDisabled(t *testing.T) {
+	sc := NewStreamConverter(nil)
+
+	opts := Options{
+		AutoFix:      true,
+		DisableRules: []string{"lowercase-attrs"},
+	}
+
+	var out bytes.Buffer
+	_, err := sc.Convert(context.Background(), strings.NewReader(`<input CHECKED VALUE="x">`), &out, opts)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `<input CHECKED="" VALUE="x" />`
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Errorf("Convert() output = %s, want %s (attribute names/values must not swap between CHECKED and VALUE)", got, want)
+	}
+}
+
+func TestDefaultConverter_ConvertStream(t *testing.T) {
+	conv := New()
+
+	var out bytes.Buffer
+	result, err := conv.ConvertStream(context.Background(), strings.NewReader(`<div><br></div>`), &out, Options{AutoFix: true})
+	if err != nil {
+		t.Fatalf("ConvertStream() error = %v", err)
+	}
+	if !result.Success {
+		t.Error("expected Success = true")
+	}
+
+	want := `<div><br /></div>`
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Errorf("ConvertStream() output = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+// pkg/converter/urlrewrite.go
+package converter
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// urlValuedMetaKeys перечисляет property/name значения <meta>, чье content - это
+// URL, а не произвольный текст - только они резолвятся rewriteRelativeURLs.
+// Без этой оговорки og:title или twitter:description, случайно похожие на
+// относительный путь, были бы испорчены ResolveReference
+var urlValuedMetaKeys = map[string]bool{
+	"og:image":              true,
+	"og:image:url":          true,
+	"og:image:secure_url":   true,
+	"og:url":                true,
+	"og:video":              true,
+	"og:video:url":          true,
+	"og:video:secure_url":   true,
+	"og:audio":              true,
+	"twitter:image":         true,
+	"twitter:image:src":     true,
+	"twitter:player":        true,
+	"twitter:player:stream": true,
+}
+
+// rewriteRelativeURLs резолвит href/src и URL-значные content (см.
+// urlValuedMetaKeys) в абсолютные относительно base - нужен опции
+// Options.RewriteRelativeURLs, чтобы извлеченные Preload/Metadata были
+// пригодны для использования вне контекста исходной страницы
+func rewriteRelativeURLs(n *html.Node, base *url.URL) {
+	if n.Type == html.ElementNode {
+		for i, attr := range n.Attr {
+			switch attr.Key {
+			case "href", "src":
+				n.Attr[i].Val = resolveURL(base, attr.Val)
+			case "content":
+				if n.Data == "meta" && isURLValuedMeta(n) {
+					n.Attr[i].Val = resolveURL(base, attr.Val)
+				}
+			}
+		}
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		rewriteRelativeURLs(child, base)
+	}
+}
+
+// isURLValuedMeta сообщает, содержит ли content этого <meta> URL, а не
+// произвольный текст, судя по его property/name
+func isURLValuedMeta(n *html.Node) bool {
+	key := nodeAttr(n, "property")
+	if key == "" {
+		key = nodeAttr(n, "name")
+	}
+	return urlValuedMetaKeys[strings.ToLower(key)]
+}
+
+// resolveURL резолвит ref относительно base. Если ref не парсится как URL,
+// возвращается исходное значение без изменений
+func resolveURL(base *url.URL, ref string) string {
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(refURL).String()
+}